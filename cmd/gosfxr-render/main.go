@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// gosfxr-render renders gosfxr configurations, layered sounds (.sfxl),
+// sequences (.sfxseq) or sfxr .sfs settings files to WAV, FLAC, Ogg Vorbis or
+// Opus files without starting the UI. It does not depend on GTK or SDL, so it can be used in
+// build pipelines.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asig/gosfxr/internal/edit"
+	"github.com/asig/gosfxr/internal/flac"
+	"github.com/asig/gosfxr/internal/generator"
+	"github.com/asig/gosfxr/internal/layered"
+	"github.com/asig/gosfxr/internal/loudness"
+	"github.com/asig/gosfxr/internal/ogg"
+	"github.com/asig/gosfxr/internal/sequencer"
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+var (
+	flagBits      = flag.Int("bits", 16, "Bits per sample of WAV and FLAC files (8, 16, 24, or 32 for float WAVs)")
+	flagFreq      = flag.Int("freq", 44100, "Sample rate in Hz")
+	flagDither    = flag.String("dither", "none", "Dither: none, tpdf or shaped")
+	flagFormat    = flag.String("format", "wav", "Output format: wav, flac, vorbis or opus")
+	flagQuality   = flag.Float64("quality", 5, "Quality of Vorbis and Opus files, from 0 to 10")
+	flagNormalize = flag.String("normalize", "none", "Normalization: none, peak or lufs")
+	flagTarget    = flag.Float64("target", 0, "Target level of the normalization in dBFS or LUFS (default -1 for peak, -23 for lufs)")
+	flagTrim      = flag.Bool("trim", false, "Trim leading and trailing silence")
+	flagThreshold = flag.Float64("trim-threshold", edit.DefaultTrimThreshold, "Level below which -trim considers samples silent, in dBFS")
+	flagFadeIn    = flag.Duration("fade-in", 0, "Length of the fade in, e.g. 5ms")
+	flagFadeOut   = flag.Duration("fade-out", 0, "Length of the fade out, e.g. 50ms")
+	flagMaxLength = flag.Duration("max-duration", generator.DefaultMaxDuration, "Sounds are cut off after this long; 0 means no limit")
+	flagBandLimit = flag.Bool("band-limited", false, "Reduce aliasing of square and sawtooth waves; sounds differ slightly from sfxr's")
+	flagOversamp  = flag.Int("oversampling", generator.DefaultOversampling, "Number of sub-samples the generator computes per output sample")
+	flagOut       = flag.String("out", "", "Output directory. If empty, files are written next to their configs.")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] config.json|sound.sfxl|jingle.sfxseq|settings.sfs...\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func outputFilename(configFilename string) string {
+	base := filepath.Base(configFilename)
+	ext := "." + *flagFormat
+	if codec, ok := codecs[*flagFormat]; ok {
+		ext = codec.Extension()
+	}
+	base = strings.TrimSuffix(base, filepath.Ext(base)) + ext
+	dir := *flagOut
+	if dir == "" {
+		dir = filepath.Dir(configFilename)
+	}
+	return filepath.Join(dir, base)
+}
+
+// readSound reads a layered sound, a sequence, a gosfxr configuration, or
+// an sfxr settings file.
+func readSound(filename string) (*layered.Sound, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case layered.Extension:
+		sound := &layered.Sound{}
+		if err := sound.InitFromJson(content); err != nil {
+			return nil, fmt.Errorf("invalid layered sound: %s", err)
+		}
+		return sound, nil
+	case sequencer.Extension:
+		seq := &sequencer.Sequence{}
+		if err := seq.InitFromJson(content); err != nil {
+			return nil, fmt.Errorf("invalid sequence: %s", err)
+		}
+		return seq.Sound(), nil
+	}
+	cfg := generator.NewConfig()
+	if ext == ".sfs" {
+		err = cfg.InitFromSfs(content)
+	} else {
+		err = cfg.InitFromJson(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	return layered.New(cfg), nil
+}
+
+func render(configFilename string) error {
+	sound, err := readSound(configFilename)
+	if err != nil {
+		return err
+	}
+
+	sample, channels, truncated := sound.Render(generatorOptions())
+	duration := generator.FramesDuration(len(sample)/channels, *flagFreq)
+	sample = edit.Apply(sample, channels, *flagFreq, editOptions())
+	sample = loudness.Normalize(sample, channels, *flagFreq, normalizeOptions())
+	// Encoding first doesn't leave a broken file behind if it fails.
+	var buf bytes.Buffer
+	if err := encode(&buf, sample, channels); err != nil {
+		return err
+	}
+	filename := outputFilename(configFilename)
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s (%d ms)\n", configFilename, filename, duration.Milliseconds())
+	if truncated {
+		fmt.Fprintf(os.Stderr, "%s was cut off after %s\n", configFilename, *flagMaxLength)
+	}
+	return nil
+}
+
+var dithers = map[string]wav.Dither{
+	"none":   wav.DitherNone,
+	"tpdf":   wav.DitherTPDF,
+	"shaped": wav.DitherShaped,
+}
+
+// codecs maps the compressed output formats to their codecs.
+var codecs = map[string]ogg.Codec{
+	"vorbis": ogg.CodecVorbis,
+	"opus":   ogg.CodecOpus,
+}
+
+// generatorOptions returns the options for rendering sounds. They are
+// generated at the output's sample rate, so they don't need resampling.
+func generatorOptions() generator.Options {
+	return generator.Options{
+		MaxDuration:  *flagMaxLength,
+		BandLimited:  *flagBandLimit,
+		SampleRate:   *flagFreq,
+		Oversampling: *flagOversamp,
+	}
+}
+
+func editOptions() edit.Options {
+	return edit.Options{Trim: *flagTrim, TrimThreshold: *flagThreshold, FadeIn: *flagFadeIn, FadeOut: *flagFadeOut}
+}
+
+var normalizeModes = map[string]loudness.Mode{
+	"none": loudness.ModeNone,
+	"peak": loudness.ModePeak,
+	"lufs": loudness.ModeLUFS,
+}
+
+// normalizeOptions returns the normalization asked for. Unless -target is
+// given, the mode's default target is used.
+func normalizeOptions() loudness.Options {
+	mode := normalizeModes[*flagNormalize]
+	opts := loudness.Options{Mode: mode, Target: loudness.DefaultTarget(mode)}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "target" {
+			opts.Target = *flagTarget
+		}
+	})
+	return opts
+}
+
+func wavOptions(channels int) wav.Options {
+	return wav.Options{Stream: wav.Stream{SampleRate: *flagFreq, SourceRate: *flagFreq, Channels: channels}, Bits: *flagBits, Dither: dithers[*flagDither]}
+}
+
+func flacOptions(channels int) flac.Options {
+	return flac.Options{Stream: wav.Stream{SampleRate: *flagFreq, SourceRate: *flagFreq, Channels: channels}, Bits: *flagBits, Dither: dithers[*flagDither]}
+}
+
+// encode writes sample, which has the given number of interleaved
+// channels, in the output format.
+func encode(w io.Writer, sample []float64, channels int) error {
+	if codec, ok := codecs[*flagFormat]; ok {
+		return ogg.Encode(w, sample, ogg.Options{Codec: codec, Stream: wav.Stream{SampleRate: *flagFreq, SourceRate: *flagFreq, Channels: channels}, Quality: *flagQuality})
+	}
+	if *flagFormat == "flac" {
+		return flac.Encode(w, sample, flacOptions(channels))
+	}
+	return wav.Encode(w, sample, wavOptions(channels))
+}
+
+// validateOptions checks the flags that describe the output format.
+func validateOptions() error {
+	if _, ok := dithers[*flagDither]; !ok {
+		return fmt.Errorf("unknown dither %q", *flagDither)
+	}
+	if err := editOptions().Validate(); err != nil {
+		return err
+	}
+	if _, ok := normalizeModes[*flagNormalize]; !ok {
+		return fmt.Errorf("unknown normalization %q", *flagNormalize)
+	}
+	if err := normalizeOptions().Validate(); err != nil {
+		return err
+	}
+	if *flagFreq <= 0 {
+		return fmt.Errorf("unsupported sample rate %d", *flagFreq)
+	}
+	if *flagOversamp < 1 {
+		return fmt.Errorf("oversampling must be at least 1")
+	}
+	if *flagQuality < 0 || *flagQuality > ogg.MaxQuality {
+		return fmt.Errorf("quality must be between 0 and %d", ogg.MaxQuality)
+	}
+	switch _, isOgg := codecs[*flagFormat]; {
+	case isOgg:
+		if !ogg.Supported {
+			return fmt.Errorf("%s is not supported in this build, which lacks the ogg build tag", *flagFormat)
+		}
+		return nil
+	case *flagFormat == "flac":
+		return flacOptions(1).Validate()
+	case *flagFormat == "wav":
+		return wavOptions(1).Validate()
+	}
+	return fmt.Errorf("unknown format %q", *flagFormat)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := validateOptions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid output format: %s\n", err)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, f := range flag.Args() {
+		if err := render(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't render %s: %s\n", f, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
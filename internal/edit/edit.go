@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package edit trims and fades rendered sounds before they are exported.
+package edit
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultTrimThreshold is the level below which samples count as silence,
+// in dBFS.
+const DefaultTrimThreshold = -60
+
+// Options control how a sound is edited.
+type Options struct {
+	// Trim removes leading and trailing frames quieter than TrimThreshold.
+	Trim bool
+	// TrimThreshold in dBFS
+	TrimThreshold float64
+	// Length of the linear fades at the start and the end of the sound
+	FadeIn, FadeOut time.Duration
+}
+
+// Validate checks whether the options are supported.
+func (o Options) Validate() error {
+	if o.Trim && o.TrimThreshold > 0 {
+		return fmt.Errorf("trim threshold %.1f is above full scale", o.TrimThreshold)
+	}
+	if o.FadeIn < 0 || o.FadeOut < 0 {
+		return fmt.Errorf("fades can't be negative")
+	}
+	return nil
+}
+
+// Apply returns the samples, which have the given number of interleaved
+// channels, trimmed and faded as opts ask for. Fades are applied after
+// trimming, so that they start and end at the trimmed edges.
+func Apply(samples []float64, channels, sampleRate int, opts Options) []float64 {
+	if opts.Trim {
+		samples = Trim(samples, channels, opts.TrimThreshold)
+	}
+	if opts.FadeIn > 0 || opts.FadeOut > 0 {
+		samples = Fade(samples, channels, frames(opts.FadeIn, sampleRate), frames(opts.FadeOut, sampleRate))
+	}
+	return samples
+}
+
+func frames(d time.Duration, sampleRate int) int {
+	return int(d.Seconds()*float64(sampleRate) + 0.5)
+}
+
+// Trim returns the part of samples between the first and the last frame
+// that has a sample at or above threshold dBFS. The result shares the
+// storage of samples.
+func Trim(samples []float64, channels int, threshold float64) []float64 {
+	level := math.Pow(10, threshold/20)
+	loud := func(frame int) bool {
+		for _, s := range samples[frame*channels : (frame+1)*channels] {
+			if math.Abs(s) >= level {
+				return true
+			}
+		}
+		return false
+	}
+	n := len(samples) / channels
+	start := 0
+	for start < n && !loud(start) {
+		start++
+	}
+	end := n
+	for end > start && !loud(end-1) {
+		end--
+	}
+	return samples[start*channels : end*channels]
+}
+
+// Fade returns a copy of samples that rises from silence during the first
+// fadeIn frames and falls back to it during the last fadeOut ones. Fades
+// longer than the sound are shortened.
+func Fade(samples []float64, channels, fadeIn, fadeOut int) []float64 {
+	n := len(samples) / channels
+	if fadeIn > n {
+		fadeIn = n
+	}
+	if fadeOut > n {
+		fadeOut = n
+	}
+	res := make([]float64, len(samples))
+	for i := 0; i < n; i++ {
+		gain := 1.0
+		if i < fadeIn {
+			gain = float64(i) / float64(fadeIn)
+		}
+		if d := n - 1 - i; d < fadeOut {
+			gain = math.Min(gain, float64(d)/float64(fadeOut))
+		}
+		for c := 0; c < channels; c++ {
+			res[i*channels+c] = samples[i*channels+c] * gain
+		}
+	}
+	return res
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package edit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		name      string
+		samples   []float64
+		channels  int
+		threshold float64
+		want      []float64
+	}{
+		{
+			name:      "Mono",
+			samples:   []float64{0, 0.0001, 0.5, 0, -0.5, 0.0001, 0},
+			channels:  1,
+			threshold: -60,
+			want:      []float64{0.5, 0, -0.5},
+		},
+		{
+			name:      "Threshold",
+			samples:   []float64{0.01, 0.5, 0.01},
+			channels:  1,
+			threshold: -20,
+			want:      []float64{0.5},
+		},
+		{
+			name:      "Stereo",
+			samples:   []float64{0, 0, 0, 0.5, 0.5, 0, 0, 0},
+			channels:  2,
+			threshold: -60,
+			want:      []float64{0, 0.5, 0.5, 0},
+		},
+		{
+			name:      "Silence",
+			samples:   []float64{0, 0, 0},
+			channels:  1,
+			threshold: -60,
+			want:      []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Trim(tt.samples, tt.channels, tt.threshold); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trim() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFade(t *testing.T) {
+	ones := func(n int) []float64 {
+		res := make([]float64, n)
+		for i := range res {
+			res[i] = 1
+		}
+		return res
+	}
+	tests := []struct {
+		name            string
+		samples         []float64
+		channels        int
+		fadeIn, fadeOut int
+		want            []float64
+	}{
+		{
+			name:    "In",
+			samples: ones(6), channels: 1, fadeIn: 4,
+			want: []float64{0, 0.25, 0.5, 0.75, 1, 1},
+		},
+		{
+			name:    "Out",
+			samples: ones(6), channels: 1, fadeOut: 4,
+			want: []float64{1, 1, 0.75, 0.5, 0.25, 0},
+		},
+		{
+			name:    "Stereo",
+			samples: ones(6), channels: 2, fadeIn: 2, fadeOut: 2,
+			want: []float64{0, 0, 0.5, 0.5, 0, 0},
+		},
+		{
+			name:    "Longer than the sound",
+			samples: ones(3), channels: 1, fadeIn: 10,
+			want: []float64{0, 1.0 / 3, 2.0 / 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fade(tt.samples, tt.channels, tt.fadeIn, tt.fadeOut); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	samples := []float64{0, 0, 1, 1, 1, 1, 1, 0}
+	opts := Options{Trim: true, TrimThreshold: DefaultTrimThreshold, FadeIn: 2 * time.Second, FadeOut: time.Second}
+	want := []float64{0, 0.5, 1, 1, 0}
+	if got := Apply(samples, 1, 1, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() = %v, want %v", got, want)
+	}
+	if got := Apply(samples, 1, 1, Options{}); !reflect.DeepEqual(got, samples) {
+		t.Errorf("Apply() with no options = %v, want %v", got, samples)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		wantErr bool
+	}{
+		{opts: Options{}, wantErr: false},
+		{opts: Options{Trim: true, TrimThreshold: -40, FadeIn: time.Millisecond}, wantErr: false},
+		{opts: Options{Trim: true, TrimThreshold: 6}, wantErr: true},
+		{opts: Options{FadeOut: -time.Millisecond}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: Validate() = %v, wantErr %t", tt.opts, err, tt.wantErr)
+		}
+	}
+}
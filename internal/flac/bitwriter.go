@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package flac
+
+import "bytes"
+
+// bitWriter collects values MSB first.
+type bitWriter struct {
+	buf   bytes.Buffer
+	acc   uint64
+	nbits uint
+}
+
+// write appends the n lowest bits of val. n must not exceed 32.
+func (w *bitWriter) write(val uint64, n uint) {
+	w.acc = w.acc<<n | val&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buf.WriteByte(byte(w.acc >> w.nbits))
+	}
+}
+
+// writeSigned appends val as an n bit two's complement number.
+func (w *bitWriter) writeSigned(val int64, n uint) {
+	w.write(uint64(val), n)
+}
+
+// writeUnary appends val zeros followed by a one.
+func (w *bitWriter) writeUnary(val uint64) {
+	for ; val >= 32; val -= 32 {
+		w.write(0, 32)
+	}
+	w.write(1, uint(val)+1)
+}
+
+// align pads with zeros up to the next byte boundary.
+func (w *bitWriter) align() {
+	if w.nbits > 0 {
+		w.write(0, 8-w.nbits)
+	}
+}
+
+// bytes returns the bytes written so far. The writer must be aligned.
+func (w *bitWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
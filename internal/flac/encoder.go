@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+// Package flac encodes sounds as FLAC files. Only the fixed predictors are
+// used, which compress sfxr's sounds nearly as well as LPC.
+package flac
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+// Options control how samples are encoded.
+type Options struct {
+	wav.Stream
+	// Bits per sample: 8, 16 or 24
+	Bits int
+	// Dither used when quantizing the samples.
+	Dither wav.Dither
+}
+
+// Validate checks whether the options are supported.
+func (o Options) Validate() error {
+	if err := o.Stream.Validate(); err != nil {
+		return err
+	}
+	if o.SampleRate >= 1<<20 {
+		return fmt.Errorf("unsupported sample rate %d", o.SampleRate)
+	}
+	if o.Bits != 8 && o.Bits != 16 && o.Bits != 24 {
+		return fmt.Errorf("unsupported bit depth %d", o.Bits)
+	}
+	return nil
+}
+
+// blockSize is the number of samples per frame.
+const blockSize = 4096
+
+// maxPartitionOrder limits the number of Rice partitions to 2^maxPartitionOrder.
+const maxPartitionOrder = 8
+
+// Sample size codes in frame headers, by bits per sample
+var sampleSizeCodes = map[int]uint64{8: 1, 16: 4, 24: 6}
+
+// Encode writes data as a FLAC file to w.
+func Encode(w io.Writer, data []float64, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	// Each channel is coded on its own
+	parts := wav.Deinterleave(opts.Prepare(data), opts.NumChannels())
+	channels := make([][]int32, len(parts))
+	for c, part := range parts {
+		channels[c] = wav.QuantizeChannel(part, opts.Bits, opts.Dither, c)
+	}
+
+	var out bitWriter
+	out.write(0x664c6143, 32) // "fLaC"
+	writeStreamInfo(&out, channels, opts)
+	frames := len(channels[0])
+	for start, frame := 0, uint64(0); start < frames; start, frame = start+blockSize, frame+1 {
+		end := start + blockSize
+		if end > frames {
+			end = frames
+		}
+		blocks := make([][]int32, len(channels))
+		for c := range channels {
+			blocks[c] = channels[c][start:end]
+		}
+		out.buf.Write(encodeFrame(blocks, frame, opts.Bits))
+	}
+
+	_, err := w.Write(out.bytes())
+	return err
+}
+
+func writeStreamInfo(w *bitWriter, channels [][]int32, opts Options) {
+	frames := len(channels[0])
+	w.write(1, 1)          // last metadata block
+	w.write(0, 7)          // STREAMINFO
+	w.write(34, 24)        // length
+	w.write(blockSize, 16) // min block size
+	w.write(blockSize, 16) // max block size
+	w.write(0, 24)         // min frame size, unknown
+	w.write(0, 24)         // max frame size, unknown
+	w.write(uint64(opts.SampleRate), 20)
+	w.write(uint64(len(channels)-1), 3)
+	w.write(uint64(opts.Bits-1), 5)
+	w.write(uint64(frames)>>32, 4)
+	w.write(uint64(frames), 32)
+
+	// MD5 of the samples in little endian, as they'd be stored in a WAV file
+	bytesPerSample := opts.Bits / 8
+	raw := make([]byte, 0, bytesPerSample*len(channels)*frames)
+	for f := 0; f < frames; f++ {
+		for _, channel := range channels {
+			for i := 0; i < bytesPerSample; i++ {
+				raw = append(raw, byte(channel[f]>>(8*uint(i))))
+			}
+		}
+	}
+	sum := md5.Sum(raw)
+	for _, b := range sum {
+		w.write(uint64(b), 8)
+	}
+}
+
+// writeUTF8 writes val with the extended UTF-8 encoding that FLAC uses for
+// frame numbers.
+func writeUTF8(w *bitWriter, val uint64) {
+	if val < 0x80 {
+		w.write(val, 8)
+		return
+	}
+	// Number of continuation bytes, each holding 6 bits
+	n := uint(1)
+	for val >= 1<<(5*n+6) {
+		n++
+	}
+	// The first byte starts with n+1 ones and a zero
+	w.write((0xff<<(7-n))&0xff|val>>(6*n), 8)
+	for i := int(n) - 1; i >= 0; i-- {
+		w.write(0x80|(val>>(6*uint(i)))&0x3f, 8)
+	}
+}
+
+// encodeFrame encodes a block of samples of each channel.
+func encodeFrame(blocks [][]int32, frame uint64, bits int) []byte {
+	size := len(blocks[0])
+	var w bitWriter
+	w.write(0xfff8, 16) // sync code, fixed block size
+	if size == blockSize {
+		w.write(12, 4) // 256 * 2^(12-8) samples
+	} else {
+		w.write(7, 4) // 16 bit block size at the end of the header
+	}
+	w.write(0, 4)                     // sample rate from STREAMINFO
+	w.write(uint64(len(blocks)-1), 4) // independent channels
+	w.write(sampleSizeCodes[bits], 3)
+	w.write(0, 1)
+	writeUTF8(&w, frame)
+	if size != blockSize {
+		w.write(uint64(size-1), 16)
+	}
+	w.write(uint64(crc8(w.bytes())), 8)
+
+	for _, samples := range blocks {
+		writeSubframe(&w, samples, uint(bits))
+	}
+
+	w.align()
+	w.write(uint64(crc16(w.bytes())), 16)
+	return w.bytes()
+}
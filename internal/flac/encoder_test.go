@@ -0,0 +1,342 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+// bitReader reads values MSB first.
+type bitReader struct {
+	data []byte
+	pos  uint // in bits
+}
+
+func (r *bitReader) read(n uint) uint64 {
+	var val uint64
+	for i := uint(0); i < n; i++ {
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		val = val<<1 | uint64(bit)
+		r.pos++
+	}
+	return val
+}
+
+func (r *bitReader) readSigned(n uint) int64 {
+	return int64(r.read(n)<<(64-n)) >> (64 - n)
+}
+
+func (r *bitReader) readUnary() uint64 {
+	var val uint64
+	for r.read(1) == 0 {
+		val++
+	}
+	return val
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+// decode is a minimal FLAC decoder that understands what Encode writes. It
+// returns interleaved samples.
+func decode(data []byte) ([]int32, int, error) {
+	r := &bitReader{data: data}
+	if r.read(32) != 0x664c6143 {
+		return nil, 0, errors.New("no fLaC marker")
+	}
+	if last, typ, l := r.read(1), r.read(7), r.read(24); last != 1 || typ != 0 || l != 34 {
+		return nil, 0, fmt.Errorf("unexpected metadata block %d/%d/%d", last, typ, l)
+	}
+	r.read(16 + 16 + 24 + 24) // block and frame sizes
+	sampleRate := int(r.read(20))
+	channels := int(r.read(3) + 1)
+	bps := uint(r.read(5) + 1)
+	total := int(r.read(36))
+	var sum [16]byte
+	for i := range sum {
+		sum[i] = byte(r.read(8))
+	}
+
+	var samples []int32
+	for frame := uint64(0); len(samples) < total*channels; frame++ {
+		start := r.pos / 8
+		if sync := r.read(16); sync != 0xfff8 {
+			return nil, 0, fmt.Errorf("frame %d: bad sync code %x", frame, sync)
+		}
+		blockSizeCode := r.read(4)
+		r.read(4) // sample rate
+		if assignment := int(r.read(4)); assignment != channels-1 {
+			return nil, 0, fmt.Errorf("frame %d: channel assignment %d", frame, assignment)
+		}
+		if code := r.read(3); code != sampleSizeCodes[int(bps)] {
+			return nil, 0, fmt.Errorf("frame %d: sample size code %d", frame, code)
+		}
+		r.read(1)
+		// frame number
+		first := r.read(8)
+		n := 0
+		for first&(0x80>>uint(n)) != 0 {
+			n++
+		}
+		num := first & (0xff >> uint(n+1))
+		for i := 1; i < n; i++ {
+			num = num<<6 | r.read(8)&0x3f
+		}
+		if num != frame {
+			return nil, 0, fmt.Errorf("frame %d: frame number %d", frame, num)
+		}
+		size := 4096
+		if blockSizeCode == 7 {
+			size = int(r.read(16) + 1)
+		} else if blockSizeCode != 12 {
+			return nil, 0, fmt.Errorf("frame %d: block size code %d", frame, blockSizeCode)
+		}
+		if crc := byte(r.read(8)); crc != crc8(data[start:r.pos/8-1]) {
+			return nil, 0, fmt.Errorf("frame %d: bad header CRC", frame)
+		}
+
+		blocks := make([][]int32, channels)
+		for c := range blocks {
+			block, err := decodeSubframe(r, size, bps)
+			if err != nil {
+				return nil, 0, fmt.Errorf("frame %d: %s", frame, err)
+			}
+			blocks[c] = block
+		}
+		for i := 0; i < size; i++ {
+			for _, block := range blocks {
+				samples = append(samples, block[i])
+			}
+		}
+
+		r.align()
+		if crc := uint16(r.read(16)); crc != crc16(data[start:r.pos/8-2]) {
+			return nil, 0, fmt.Errorf("frame %d: bad CRC", frame)
+		}
+	}
+	if r.pos/8 != uint(len(data)) {
+		return nil, 0, fmt.Errorf("%d bytes after last frame", uint(len(data))-r.pos/8)
+	}
+
+	var raw []byte
+	for _, s := range samples {
+		for i := uint(0); i < bps/8; i++ {
+			raw = append(raw, byte(s>>(8*i)))
+		}
+	}
+	if md5.Sum(raw) != sum {
+		return nil, 0, errors.New("MD5 mismatch")
+	}
+	return samples, sampleRate, nil
+}
+
+func decodeSubframe(r *bitReader, size int, bps uint) ([]int32, error) {
+	header := r.read(8)
+	typ := header >> 1
+	samples := make([]int32, size)
+	switch {
+	case typ == subframeConstant:
+		v := int32(r.readSigned(bps))
+		for i := range samples {
+			samples[i] = v
+		}
+	case typ == subframeVerbatim:
+		for i := range samples {
+			samples[i] = int32(r.readSigned(bps))
+		}
+	case typ&^7 == subframeFixed && typ&7 <= maxFixedOrder:
+		order := int(typ & 7)
+		for i := 0; i < order; i++ {
+			samples[i] = int32(r.readSigned(bps))
+		}
+		method := r.read(2)
+		partitions := 1 << r.read(4)
+		paramBits := uint(4 + method)
+		i := order
+		for p := 0; p < partitions; p++ {
+			k := uint(r.read(paramBits))
+			end := (p + 1) * size / partitions
+			for ; i < end; i++ {
+				u := r.readUnary()<<k | r.read(k)
+				res := int64(u>>1) ^ -int64(u&1)
+				s := func(j int) int64 { return int64(samples[i-j]) }
+				var pred int64
+				switch order {
+				case 1:
+					pred = s(1)
+				case 2:
+					pred = 2*s(1) - s(2)
+				case 3:
+					pred = 3*s(1) - 3*s(2) + s(3)
+				case 4:
+					pred = 4*s(1) - 6*s(2) + 4*s(3) - s(4)
+				}
+				samples[i] = int32(pred + res)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected subframe header %x", header)
+	}
+	return samples, nil
+}
+
+func genSine(l int) []float64 {
+	buf := make([]float64, l)
+	for i := range buf {
+		buf[i] = math.Sin(math.Pi / 22050.0 * float64(i) * 440)
+	}
+	return buf
+}
+
+func genNoise(l int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	buf := make([]float64, l)
+	for i := range buf {
+		buf[i] = 2*rng.Float64() - 1
+	}
+	return buf
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []float64
+		opts Options
+	}{
+		{name: "8bit", data: genSine(441), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 8}},
+		{name: "16bit", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16}},
+		{name: "24bit", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 24}},
+		{name: "16bit 22kHz", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 22050}, Bits: 16}},
+		{name: "dither", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16, Dither: wav.DitherShaped}},
+		{name: "noise", data: genNoise(5000), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16}},
+		{name: "silence", data: make([]float64, 8192), opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16}},
+		{name: "one sample", data: []float64{0.5}, opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 24}},
+		{name: "empty", data: nil, opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16}},
+		{name: "stereo", data: append(genSine(5000), genNoise(5000)...), opts: Options{Stream: wav.Stream{SampleRate: 44100, Channels: 2}, Bits: 16}},
+		{name: "stereo 24bit 48kHz", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 48000, Channels: 2}, Bits: 24}},
+		{name: "48kHz source", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 48000, SourceRate: 48000}, Bits: 16}},
+		{name: "48kHz source to 44.1kHz", data: genSine(10000), opts: Options{Stream: wav.Stream{SampleRate: 44100, SourceRate: 48000}, Bits: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tt.data, tt.opts); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			got, sampleRate, err := decode(buf.Bytes())
+			if err != nil {
+				t.Fatalf("Can't decode: %s", err)
+			}
+			if sampleRate != tt.opts.SampleRate {
+				t.Errorf("sample rate = %d, want %d", sampleRate, tt.opts.SampleRate)
+			}
+			var want []int32
+			for c, part := range wav.Deinterleave(tt.opts.Prepare(tt.data), tt.opts.NumChannels()) {
+				q := wav.QuantizeChannel(part, tt.opts.Bits, tt.opts.Dither, c)
+				if c == 0 {
+					want = make([]int32, len(q)*tt.opts.NumChannels())
+				}
+				for i, v := range q {
+					want[i*tt.opts.NumChannels()+c] = v
+				}
+			}
+			if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Errorf("decoded samples differ from the quantized input")
+			}
+		})
+	}
+}
+
+func TestEncodeCompresses(t *testing.T) {
+	data := genSine(44100)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 16}); err != nil {
+		t.Fatalf("Encode() failed: %s", err)
+	}
+	if raw := 2 * len(data); buf.Len() > raw/2 {
+		t.Errorf("FLAC file has %d bytes, want at most half of %d", buf.Len(), raw)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "32bit", opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 32}},
+		{name: "12bit", opts: Options{Stream: wav.Stream{SampleRate: 44100}, Bits: 12}},
+		{name: "sample rate 0", opts: Options{Stream: wav.Stream{SampleRate: 0}, Bits: 16}},
+		{name: "sample rate too high", opts: Options{Stream: wav.Stream{SampleRate: 1 << 20}, Bits: 16}},
+		{name: "too many channels", opts: Options{Stream: wav.Stream{SampleRate: 44100, Channels: 3}, Bits: 16}},
+		{name: "negative source rate", opts: Options{Stream: wav.Stream{SampleRate: 44100, SourceRate: -1}, Bits: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(441), tt.opts); err == nil {
+				t.Errorf("Encode() succeeded with %+v", tt.opts)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Encode() wrote %d bytes", buf.Len())
+			}
+		})
+	}
+}
+
+func TestWriteUTF8(t *testing.T) {
+	tests := []struct {
+		val  uint64
+		want []byte
+	}{
+		{val: 0, want: []byte{0x00}},
+		{val: 0x7f, want: []byte{0x7f}},
+		{val: 0x80, want: []byte{0xc2, 0x80}},
+		{val: 0x7ff, want: []byte{0xdf, 0xbf}},
+		{val: 0x800, want: []byte{0xe0, 0xa0, 0x80}},
+		{val: 0x10000, want: []byte{0xf0, 0x90, 0x80, 0x80}},
+		{val: 1<<36 - 1, want: []byte{0xfe, 0xbf, 0xbf, 0xbf, 0xbf, 0xbf, 0xbf}},
+	}
+	for _, tt := range tests {
+		var w bitWriter
+		writeUTF8(&w, tt.val)
+		if got := w.bytes(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("writeUTF8(%x) = %x, want %x", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestCRC(t *testing.T) {
+	data := []byte("123456789")
+	if got := crc8(data); got != 0xf4 {
+		t.Errorf("crc8() = %02x, want f4", got)
+	}
+	if got := crc16(data); got != 0xfee8 {
+		t.Errorf("crc16() = %04x, want fee8", got)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package flac
+
+// Subframe types, as they appear in the subframe header
+const (
+	subframeConstant = 0x00
+	subframeVerbatim = 0x01
+	subframeFixed    = 0x08
+)
+
+// Highest order of the fixed predictors
+const maxFixedOrder = 4
+
+// fixedResidual returns the residual of the fixed predictor of the given
+// order, i.e. the order-th difference of the samples.
+func fixedResidual(samples []int32, order int) []int64 {
+	res := make([]int64, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		s := func(j int) int64 { return int64(samples[i-j]) }
+		switch order {
+		case 0:
+			res[i] = s(0)
+		case 1:
+			res[i-1] = s(0) - s(1)
+		case 2:
+			res[i-2] = s(0) - 2*s(1) + s(2)
+		case 3:
+			res[i-3] = s(0) - 3*s(1) + 3*s(2) - s(3)
+		case 4:
+			res[i-4] = s(0) - 4*s(1) + 6*s(2) - 4*s(3) + s(4)
+		}
+	}
+	return res
+}
+
+// riceCoding describes how a residual is Rice coded.
+type riceCoding struct {
+	// 0 for 4 bit parameters, 1 for 5 bit parameters
+	method         uint64
+	partitionOrder uint
+	// Samples per partition. The first partition is shorter by the
+	// predictor order, because the warm-up samples aren't coded.
+	partitionSize int
+	params        []uint
+	// Size of the coded residual
+	bits int
+}
+
+func zigzag(r int64) uint64 {
+	return uint64(r<<1 ^ r>>63)
+}
+
+// bestRiceParam returns the Rice parameter that codes the values with the
+// fewest bits, and the number of bits.
+func bestRiceParam(values []uint64, maxParam uint) (uint, int) {
+	bestParam, bestBits := uint(0), -1
+	for k := uint(0); k <= maxParam; k++ {
+		bits := len(values) * int(k+1)
+		for _, u := range values {
+			bits += int(u >> k)
+		}
+		if bestBits < 0 || bits < bestBits {
+			bestParam, bestBits = k, bits
+		}
+	}
+	return bestParam, bestBits
+}
+
+// bestRiceCoding picks the partition order and Rice parameters for the
+// residual of a predictor with the given order over n samples.
+func bestRiceCoding(residual []int64, order, n int, bps uint) riceCoding {
+	values := make([]uint64, len(residual))
+	for i, r := range residual {
+		values[i] = zigzag(r)
+	}
+	method, paramBits, maxParam := uint64(0), 4, uint(14)
+	if bps > 16 {
+		method, paramBits, maxParam = 1, 5, 30
+	}
+
+	var best riceCoding
+	for p := uint(0); p <= maxPartitionOrder; p++ {
+		partitionSize := n >> p
+		if n%(1<<p) != 0 || partitionSize <= order {
+			break
+		}
+		coding := riceCoding{method: method, partitionOrder: p, partitionSize: partitionSize, bits: 2 + 4}
+		start := 0
+		for i := 0; i < 1<<p; i++ {
+			end := start + partitionSize
+			if i == 0 {
+				end -= order
+			}
+			k, bits := bestRiceParam(values[start:end], maxParam)
+			coding.params = append(coding.params, k)
+			coding.bits += paramBits + bits
+			start = end
+		}
+		if best.params == nil || coding.bits < best.bits {
+			best = coding
+		}
+	}
+	return best
+}
+
+func writeResidual(w *bitWriter, residual []int64, coding riceCoding) {
+	w.write(coding.method, 2)
+	w.write(uint64(coding.partitionOrder), 4)
+	paramBits := uint(4 + coding.method)
+	order := coding.partitionSize*len(coding.params) - len(residual)
+	start := 0
+	for i, k := range coding.params {
+		end := (i+1)*coding.partitionSize - order
+		w.write(uint64(k), paramBits)
+		for _, r := range residual[start:end] {
+			u := zigzag(r)
+			w.writeUnary(u >> k)
+			w.write(u, k)
+		}
+		start = end
+	}
+}
+
+func isConstant(samples []int32) bool {
+	for _, s := range samples {
+		if s != samples[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeSubframe writes the smallest of a constant, verbatim or fixed
+// subframe.
+func writeSubframe(w *bitWriter, samples []int32, bps uint) {
+	if isConstant(samples) {
+		w.write(subframeConstant<<1, 8)
+		w.writeSigned(int64(samples[0]), bps)
+		return
+	}
+
+	bestOrder, bestBits := -1, len(samples)*int(bps)
+	var bestResidual []int64
+	var bestCoding riceCoding
+	for order := 0; order <= maxFixedOrder && order < len(samples); order++ {
+		residual := fixedResidual(samples, order)
+		coding := bestRiceCoding(residual, order, len(samples), bps)
+		if bits := order*int(bps) + coding.bits; bits < bestBits {
+			bestOrder, bestBits = order, bits
+			bestResidual, bestCoding = residual, coding
+		}
+	}
+
+	if bestOrder < 0 {
+		w.write(subframeVerbatim<<1, 8)
+		for _, s := range samples {
+			w.writeSigned(int64(s), bps)
+		}
+		return
+	}
+	w.write(uint64(subframeFixed|bestOrder)<<1, 8)
+	for _, s := range samples[:bestOrder] {
+		w.writeSigned(int64(s), bps)
+	}
+	writeResidual(w, bestResidual, bestCoding)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import "math"
+
+// Length of the decimation filter, in samples
+const decimationTaps = 32
+
+// decimationFilter returns a windowed sinc low-pass for the given
+// oversampling. It removes everything above 20 kHz at 44.1 kHz, or the same
+// fraction of other sample rates, before the supersamples are reduced to
+// samples.
+func decimationFilter(oversampling int) []float64 {
+	return lowPass(decimationTaps*oversampling, 20000.0/(float64(oversampling)*DefaultSampleRate))
+}
+
+// lowPass returns the coefficients of a Blackman-windowed sinc filter with
+// the given cutoff, relative to the sample rate. The coefficients add up to 1.
+func lowPass(taps int, cutoff float64) []float64 {
+	h := make([]float64, taps)
+	m := float64(taps - 1)
+	sum := 0.0
+	for i := range h {
+		x := float64(i) - m/2
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		window := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/m) + 0.08*math.Cos(4*math.Pi*float64(i)/m)
+		h[i] = sinc * window
+		sum += h[i]
+	}
+	for i := range h {
+		h[i] /= sum
+	}
+	return h
+}
+
+// decimate adds a supersample to the decimation filter.
+func (v *voice) decimate(sample float64) {
+	v.dec_buffer[v.dec_pos] = sample
+	v.dec_pos = (v.dec_pos + 1) % len(v.dec_buffer)
+}
+
+// decimated returns the output of the decimation filter. It lags behind
+// the supersamples by half the filter's length.
+func (v *voice) decimated() float64 {
+	sum := 0.0
+	for i, h := range v.dec_filter {
+		sum += h * v.dec_buffer[(v.dec_pos+i)%len(v.dec_buffer)]
+	}
+	return sum
+}
+
+// polyBLEP returns what to add to smooth a step of +2 at phase 0 of an
+// oscillator at phase t that advances by dt per sample.
+func polyBLEP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t /= dt
+		return t + t - t*t - 1
+	case t > 1-dt:
+		t = (t - 1) / dt
+		return t*t + t + t + 1
+	}
+	return 0
+}
+
+// bandLimitedSquare returns the current sample of a square wave with the
+// same duty cycle and amplitude as the naive one.
+func (v *voice) bandLimitedSquare() float64 {
+	t, dt := v.bl_phase, v.bl_dt
+	sample := -0.5
+	if t > v.square_duty {
+		sample = 0.5
+	}
+	// It falls at phase 0 and rises at the duty cycle.
+	sample -= 0.5 * polyBLEP(t, dt)
+	sample += 0.5 * polyBLEP(math.Mod(t-v.square_duty+1, 1), dt)
+	return sample
+}
+
+// bandLimitedSawtooth returns the current sample of a falling sawtooth wave.
+func (v *voice) bandLimitedSawtooth() float64 {
+	t := v.bl_phase
+	return 1 - 2*t + polyBLEP(t, v.bl_dt)
+}
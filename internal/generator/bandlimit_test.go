@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPolyBLEP(t *testing.T) {
+	tests := []struct {
+		t, dt, want float64
+	}{
+		{0, 0.1, -1},
+		{0.05, 0.1, -0.25},
+		{0.5, 0.1, 0},
+		{0.95, 0.1, 0.25},
+		{0.1, 0.1, 0},
+		{0.9, 0.1, 0},
+	}
+	for _, tt := range tests {
+		if got := polyBLEP(tt.t, tt.dt); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("polyBLEP(%f, %f) = %f, want %f", tt.t, tt.dt, got, tt.want)
+		}
+	}
+}
+
+// response returns the gain of filter h at freq, in dB.
+func response(h []float64, freq float64) float64 {
+	re, im := 0.0, 0.0
+	for i, c := range h {
+		a := 2 * math.Pi * freq / (8 * DefaultSampleRate) * float64(i)
+		re += c * math.Cos(a)
+		im -= c * math.Sin(a)
+	}
+	return 10 * math.Log10(re*re+im*im)
+}
+
+func TestDecimationFilter(t *testing.T) {
+	tests := []struct {
+		freq float64
+		min  float64
+		max  float64
+	}{
+		{0, -0.01, 0.01},
+		{1000, -0.01, 0.01},
+		{15000, -0.1, 0.01},
+		{24000, math.Inf(-1), -60},
+		{100000, math.Inf(-1), -70},
+	}
+	for _, tt := range tests {
+		if got := response(decimationFilter(DefaultOversampling), tt.freq); got < tt.min || got > tt.max {
+			t.Errorf("gain at %.0f Hz is %.2f dB, want between %.2f and %.2f", tt.freq, got, tt.min, tt.max)
+		}
+	}
+}
+
+// aliasing returns how much of the energy of samples, in dB, is not close to
+// a harmonic of f0.
+func aliasing(samples []float64, f0 float64) float64 {
+	n := len(samples)
+	binHz := float64(DefaultSampleRate) / float64(n)
+	total, alias := 0.0, 0.0
+	for k := 1; k < n/2; k++ {
+		re, im := 0.0, 0.0
+		for i, s := range samples {
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)) // Hann window
+			a := 2 * math.Pi * float64(k*i) / float64(n)
+			re += s * w * math.Cos(a)
+			im -= s * w * math.Sin(a)
+		}
+		p := re*re + im*im
+		total += p
+		if f := float64(k) * binHz; math.Abs(f-math.Round(f/f0)*f0) > 3*binHz {
+			alias += p
+		}
+	}
+	return 10 * math.Log10(alias/total)
+}
+
+func TestBandLimited(t *testing.T) {
+	for _, wf := range []Waveform{WaveformSquare, WaveformSawtooth} {
+		cfg := NewConfig()
+		cfg.Waveform = wf
+		cfg.EnvelopeAttack, cfg.EnvelopeSustain, cfg.EnvelopeSustainPunch, cfg.EnvelopeDecay = 0, 0.5, 0, 0
+		cfg.FreqStart = 0.8
+		// Supersamples per period. sfxr rounds it down, the band-limited
+		// oscillators don't.
+		period := 100 / (cfg.FreqStart*cfg.FreqStart + 0.001)
+
+		naive := aliasing(NewWithOptions(cfg, Options{}).Generate()[4096:6144], 8*DefaultSampleRate/math.Floor(period))
+		bandLimited := aliasing(NewWithOptions(cfg, Options{BandLimited: true}).Generate()[4096:6144], 8*DefaultSampleRate/period)
+		if bandLimited > naive-15 {
+			t.Errorf("waveform %d: aliasing is %.1f dB band-limited and %.1f dB naive, want at least 15 dB less", wf, bandLimited, naive)
+		}
+	}
+}
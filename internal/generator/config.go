@@ -31,6 +31,16 @@ const (
 	WaveformSawtooth
 	WaveformSine
 	WaveformNoise
+	WaveformTriangle
+	WaveformBreaker
+	WaveformTan
+	// WaveformWhistle is a sine with a quiet overtone 20 times as high.
+	WaveformWhistle
+	WaveformPinkNoise
+	WaveformBrownNoise
+	// WaveformLFSRNoise is 1-bit noise from a 15-bit LFSR that is clocked
+	// once per period, like the NES noise channel.
+	WaveformLFSRNoise
 )
 
 /*
@@ -62,16 +72,27 @@ sed -e 's/p_hpf_ramp/HPCutoffSweep/g' \
 
 */
 
-func frnd(r float64) float64 {
-	return float64(rand.Int31n(10000)) / 10000.0 * r
+// NewRand returns a random source for the presets, Mutate and Randomize.
+// Using the same seed yields the same configuration.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
 }
 
-func rnd(n int32) int32 {
-	return rand.Int31n(n + 1)
+// random provides sfxr's random helpers on top of a *rand.Rand.
+type random struct {
+	*rand.Rand
 }
 
-func brnd() bool {
-	return rand.Int31n(2) > 0
+func (r random) frnd(v float64) float64 {
+	return float64(r.Int31n(10000)) / 10000.0 * v
+}
+
+func (r random) rnd(n int32) int32 {
+	return r.Int31n(n + 1)
+}
+
+func (r random) brnd() bool {
+	return r.Int31n(2) > 0
 }
 
 type Config struct {
@@ -93,7 +114,7 @@ type Config struct {
 	// Vibrato
 	VibDepth float64 `json:"vib_strength"`
 	VibSpeed float64 `json:"vib_speed"`
-	VibDelay float64 `json:"vid_delay"`
+	VibDelay float64 `json:"vib_delay"`
 
     // Arpaggio
 	ArpFreqMult    float64 `json:"arp_mod"`
@@ -117,6 +138,43 @@ type Config struct {
 	// High-Pass Filter
 	HPCutoffFreq  float64 `json:"hpf_freq"`
 	HPCutoffSweep float64 `json:"hpf_ramp"`
+
+	// Bit crush. Depth 0 leaves the samples alone, 1 reduces them to 1 bit.
+	BitCrush      float64 `json:"bitcrush"`
+	BitCrushSweep float64 `json:"bitcrush_ramp"`
+
+	// Sample rate reduction. Downsample 0 keeps every sample, 1 holds each
+	// one for 32 samples.
+	Downsample      float64 `json:"downsample"`
+	DownsampleSweep float64 `json:"downsample_ramp"`
+
+	// Compressor. It is off unless the ratio or the makeup gain is set, and
+	// then replaces the hard clipping of the output by a soft one.
+	// Threshold from 0 (-40 dB) to 1 (0 dB)
+	CompThreshold float64 `json:"comp_threshold"`
+	// Ratio from 0 (1:1) to 1 (a limiter)
+	CompRatio float64 `json:"comp_ratio"`
+	// Makeup gain from 0 (0 dB) to 1 (+24 dB)
+	CompMakeup float64 `json:"comp_makeup"`
+
+	// Stereo. Sounds are mono unless one of these is set.
+	// Pan from -1 (left) to 1 (right)
+	Pan float64 `json:"pan"`
+	// StereoSpread delays the phaser of the right channel
+	StereoSpread float64 `json:"stereo_spread"`
+	// StereoDetune lowers the left channel and raises the right one
+	StereoDetune float64 `json:"stereo_detune"`
+
+	// Seed for the noise waveform
+	Seed int64 `json:"seed"`
+}
+
+// Channels returns 2 for stereo sounds, 1 otherwise.
+func (g *Config) Channels() int {
+	if g.Pan != 0 || g.StereoSpread != 0 || g.StereoDetune != 0 {
+		return 2
+	}
+	return 1
 }
 
 func NewConfig() *Config {
@@ -125,8 +183,36 @@ func NewConfig() *Config {
 	return g
 }
 
-func (g *Config) InitFromJson(j []byte) {
-	json.Unmarshal(j, g)
+// InitFromJson replaces g with the configuration in j. Values missing from
+// j, e.g. in files written before they were added, get their defaults.
+func (g *Config) InitFromJson(j []byte) error {
+	res := NewConfig()
+	if err := json.Unmarshal(j, res); err != nil {
+		return err
+	}
+	*g = *res
+	return nil
+}
+
+// UnmarshalJSON decodes a configuration. Older versions of gosfxr stored
+// the vibrato delay as "vid_delay", which is still understood.
+func (g *Config) UnmarshalJSON(j []byte) error {
+	type config Config // config has no methods, so this doesn't recurse
+	aux := struct {
+		*config
+		VibDelay       *float64 `json:"vib_delay"`
+		LegacyVibDelay *float64 `json:"vid_delay"`
+	}{config: (*config)(g)}
+	if err := json.Unmarshal(j, &aux); err != nil {
+		return err
+	}
+	switch {
+	case aux.VibDelay != nil:
+		g.VibDelay = *aux.VibDelay
+	case aux.LegacyVibDelay != nil:
+		g.VibDelay = *aux.LegacyVibDelay
+	}
+	return nil
 }
 
 func (g *Config) ToJson() []byte {
@@ -160,6 +246,15 @@ func (g *Config) Reset() {
 	g.HPCutoffFreq = 0.0
 	g.HPCutoffSweep = 0.0
 
+	g.BitCrush = 0.0
+	g.BitCrushSweep = 0.0
+	g.Downsample = 0.0
+	g.DownsampleSweep = 0.0
+
+	g.CompThreshold = 0.5
+	g.CompRatio = 0.0
+	g.CompMakeup = 0.0
+
 	g.PhaserOffset = 0.0
 	g.PhaserSweep = 0.0
 
@@ -167,279 +262,349 @@ func (g *Config) Reset() {
 
 	g.ArpChangeSpeed = 0.0
 	g.ArpFreqMult = 0.0
+
+	g.Pan = 0.0
+	g.StereoSpread = 0.0
+	g.StereoDetune = 0.0
+
+	g.Seed = 0
 }
 
-func (g *Config) PresetPickup() {
+// Transpose shifts the pitch of the sound by the given number of
+// semitones. Only the start frequency and the frequency cutoff change; the
+// slides, the vibrato and the arpeggio are relative to them.
+func (g *Config) Transpose(semitones float64) {
+	ratio := math.Pow(2, semitones/12)
+	g.FreqStart = transposeFreq(g.FreqStart, ratio)
+	if g.FreqMinCutoff > 0 {
+		g.FreqMinCutoff = transposeFreq(g.FreqMinCutoff, ratio)
+	}
+}
+
+// transposeFreq multiplies the frequency that the slider value f stands for
+// by ratio. The generator's period is proportional to 1/(f²+0.001).
+func transposeFreq(f, ratio float64) float64 {
+	sq := (f*f+0.001)*ratio - 0.001
+	if sq <= 0 {
+		return 0
+	}
+	return math.Sqrt(sq)
+}
+
+func (g *Config) PresetPickup(rng *rand.Rand) {
 	g.Reset()
-	g.FreqStart = 0.4 + frnd(0.5)
+	g.Seed = rng.Int63()
+	r := random{rng}
+	g.FreqStart = 0.4 + r.frnd(0.5)
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = frnd(0.1)
-	g.EnvelopeDecay = 0.1 + frnd(0.4)
-	g.EnvelopeSustainPunch = 0.3 + frnd(0.3)
-	if brnd() {
-		g.ArpChangeSpeed = 0.5 + frnd(0.2)
-		g.ArpFreqMult = 0.2 + frnd(0.4)
+	g.EnvelopeSustain = r.frnd(0.1)
+	g.EnvelopeDecay = 0.1 + r.frnd(0.4)
+	g.EnvelopeSustainPunch = 0.3 + r.frnd(0.3)
+	if r.brnd() {
+		g.ArpChangeSpeed = 0.5 + r.frnd(0.2)
+		g.ArpFreqMult = 0.2 + r.frnd(0.4)
 	}
 }
 
-func (g *Config) PresetLaser() {
+func (g *Config) PresetLaser(rng *rand.Rand) {
 	g.Reset()
-	g.Waveform = Waveform(rnd(2))
-	if g.Waveform == WaveformSine && rnd(1) == 1 {
+	g.Seed = rng.Int63()
+	r := random{rng}
+	g.Waveform = Waveform(r.rnd(2))
+	if g.Waveform == WaveformSine && r.rnd(1) == 1 {
 		// Make Sine less propable? But why?
-		g.Waveform = Waveform(rnd(1))
+		g.Waveform = Waveform(r.rnd(1))
 	}
 
-	g.FreqStart = 0.5 + frnd(0.5)
-	g.FreqMinCutoff = g.FreqStart - 0.2 - frnd(0.6)
+	g.FreqStart = 0.5 + r.frnd(0.5)
+	g.FreqMinCutoff = g.FreqStart - 0.2 - r.frnd(0.6)
 	if g.FreqMinCutoff < 0.2 {
 		g.FreqMinCutoff = 0.2
 	}
-	g.FreqSlide = -0.15 - frnd(0.2)
-	if rnd(2) == 0 {
-		g.FreqStart = 0.3 + frnd(0.6)
-		g.FreqMinCutoff = frnd(0.1)
-		g.FreqSlide = -0.35 - frnd(0.3)
+	g.FreqSlide = -0.15 - r.frnd(0.2)
+	if r.rnd(2) == 0 {
+		g.FreqStart = 0.3 + r.frnd(0.6)
+		g.FreqMinCutoff = r.frnd(0.1)
+		g.FreqSlide = -0.35 - r.frnd(0.3)
 	}
-	if brnd() {
-		g.DutyCycle = frnd(0.5)
-		g.DutyCycleSweep = frnd(0.2)
+	if r.brnd() {
+		g.DutyCycle = r.frnd(0.5)
+		g.DutyCycleSweep = r.frnd(0.2)
 	} else {
-		g.DutyCycle = 0.4 + frnd(0.5)
-		g.DutyCycleSweep = -frnd(0.7)
+		g.DutyCycle = 0.4 + r.frnd(0.5)
+		g.DutyCycleSweep = -r.frnd(0.7)
 	}
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = 0.1 + frnd(0.2)
-	g.EnvelopeDecay = frnd(0.4)
-	if brnd() {
-		g.EnvelopeSustainPunch = frnd(0.3)
+	g.EnvelopeSustain = 0.1 + r.frnd(0.2)
+	g.EnvelopeDecay = r.frnd(0.4)
+	if r.brnd() {
+		g.EnvelopeSustainPunch = r.frnd(0.3)
 	}
-	if rnd(2) == 0 {
-		g.PhaserOffset = frnd(0.2)
-		g.PhaserSweep = -frnd(0.2)
+	if r.rnd(2) == 0 {
+		g.PhaserOffset = r.frnd(0.2)
+		g.PhaserSweep = -r.frnd(0.2)
 	}
-	if brnd() {
-		g.HPCutoffFreq = frnd(0.3)
+	if r.brnd() {
+		g.HPCutoffFreq = r.frnd(0.3)
 	}
 }
 
-func (g *Config) PresetExplosion() {
+func (g *Config) PresetExplosion(rng *rand.Rand) {
 	g.Reset()
+	g.Seed = rng.Int63()
+	r := random{rng}
 	g.Waveform = WaveformNoise
-	if brnd() {
-		g.FreqStart = 0.1 + frnd(0.4)
-		g.FreqSlide = -0.1 + frnd(0.4)
+	if r.brnd() {
+		g.FreqStart = 0.1 + r.frnd(0.4)
+		g.FreqSlide = -0.1 + r.frnd(0.4)
 	} else {
-		g.FreqStart = 0.2 + frnd(0.7)
-		g.FreqSlide = -0.2 - frnd(0.2)
+		g.FreqStart = 0.2 + r.frnd(0.7)
+		g.FreqSlide = -0.2 - r.frnd(0.2)
 	}
 	g.FreqStart *= g.FreqStart
-	if rnd(4) == 0 {
+	if r.rnd(4) == 0 {
 		g.FreqSlide = 0.0
 	}
-	if rnd(2) == 0 {
-		g.RepeatRate = 0.3 + frnd(0.5)
+	if r.rnd(2) == 0 {
+		g.RepeatRate = 0.3 + r.frnd(0.5)
 	}
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = 0.1 + frnd(0.3)
-	g.EnvelopeDecay = frnd(0.5)
-	if rnd(1) == 0 {
-		g.PhaserOffset = -0.3 + frnd(0.9)
-		g.PhaserSweep = -frnd(0.3)
+	g.EnvelopeSustain = 0.1 + r.frnd(0.3)
+	g.EnvelopeDecay = r.frnd(0.5)
+	if r.rnd(1) == 0 {
+		g.PhaserOffset = -0.3 + r.frnd(0.9)
+		g.PhaserSweep = -r.frnd(0.3)
 	}
-	g.EnvelopeSustainPunch = 0.2 + frnd(0.6)
-	if brnd() {
-		g.VibDepth = frnd(0.7)
-		g.VibSpeed = frnd(0.6)
+	g.EnvelopeSustainPunch = 0.2 + r.frnd(0.6)
+	if r.brnd() {
+		g.VibDepth = r.frnd(0.7)
+		g.VibSpeed = r.frnd(0.6)
 	}
-	if rnd(2) == 0 {
-		g.ArpChangeSpeed = 0.6 + frnd(0.3)
-		g.ArpFreqMult = 0.8 - frnd(1.6)
+	if r.rnd(2) == 0 {
+		g.ArpChangeSpeed = 0.6 + r.frnd(0.3)
+		g.ArpFreqMult = 0.8 - r.frnd(1.6)
 	}
 }
 
-func (g *Config) PresetPowerup() {
+func (g *Config) PresetPowerup(rng *rand.Rand) {
 	g.Reset()
-	if brnd() {
+	g.Seed = rng.Int63()
+	r := random{rng}
+	if r.brnd() {
 		g.Waveform = WaveformSawtooth
 	} else {
-		g.DutyCycle = frnd(0.6)
+		g.DutyCycle = r.frnd(0.6)
 	}
 
-	if brnd() {
-		g.FreqStart = 0.2 + frnd(0.3)
-		g.FreqSlide = 0.1 + frnd(0.4)
-		g.RepeatRate = 0.4 + frnd(0.4)
+	if r.brnd() {
+		g.FreqStart = 0.2 + r.frnd(0.3)
+		g.FreqSlide = 0.1 + r.frnd(0.4)
+		g.RepeatRate = 0.4 + r.frnd(0.4)
 	} else {
-		g.FreqStart = 0.2 + frnd(0.3)
-		g.FreqSlide = 0.05 + frnd(0.2)
-		if brnd() {
-			g.VibDepth = frnd(0.7)
-			g.VibSpeed = frnd(0.6)
+		g.FreqStart = 0.2 + r.frnd(0.3)
+		g.FreqSlide = 0.05 + r.frnd(0.2)
+		if r.brnd() {
+			g.VibDepth = r.frnd(0.7)
+			g.VibSpeed = r.frnd(0.6)
 		}
 	}
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = frnd(0.4)
-	g.EnvelopeDecay = 0.1 + frnd(0.4)
+	g.EnvelopeSustain = r.frnd(0.4)
+	g.EnvelopeDecay = 0.1 + r.frnd(0.4)
 }
 
-func (g *Config) PresetHit() {
+func (g *Config) PresetHit(rng *rand.Rand) {
 	g.Reset()
-	g.Waveform = Waveform(rnd(2))
+	g.Seed = rng.Int63()
+	r := random{rng}
+	g.Waveform = Waveform(r.rnd(2))
 	if g.Waveform == WaveformSine {
 		g.Waveform = WaveformNoise
 	}
 	if g.Waveform == WaveformSquare {
-		g.DutyCycle = frnd(0.6)
+		g.DutyCycle = r.frnd(0.6)
 	}
-	g.FreqStart = 0.2 + frnd(0.6)
-	g.FreqSlide = -0.3 - frnd(0.4)
+	g.FreqStart = 0.2 + r.frnd(0.6)
+	g.FreqSlide = -0.3 - r.frnd(0.4)
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = frnd(0.1)
-	g.EnvelopeDecay = 0.1 + frnd(0.2)
-	if brnd() {
-		g.HPCutoffFreq = frnd(0.3)
+	g.EnvelopeSustain = r.frnd(0.1)
+	g.EnvelopeDecay = 0.1 + r.frnd(0.2)
+	if r.brnd() {
+		g.HPCutoffFreq = r.frnd(0.3)
 	}
 }
 
-func (g *Config) PresetJump() {
+func (g *Config) PresetJump(rng *rand.Rand) {
 	g.Reset()
+	g.Seed = rng.Int63()
+	r := random{rng}
 	g.Waveform = WaveformSquare
-	g.DutyCycle = frnd(0.6)
-	g.FreqStart = 0.3 + frnd(0.3)
-	g.FreqSlide = 0.1 + frnd(0.2)
+	g.DutyCycle = r.frnd(0.6)
+	g.FreqStart = 0.3 + r.frnd(0.3)
+	g.FreqSlide = 0.1 + r.frnd(0.2)
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = 0.1 + frnd(0.3)
-	g.EnvelopeDecay = 0.1 + frnd(0.2)
-	if brnd() {
-		g.HPCutoffFreq = frnd(0.3)
+	g.EnvelopeSustain = 0.1 + r.frnd(0.3)
+	g.EnvelopeDecay = 0.1 + r.frnd(0.2)
+	if r.brnd() {
+		g.HPCutoffFreq = r.frnd(0.3)
 	}
-	if brnd() {
-		g.LPCutoffFreq = 1.0 - frnd(0.6)
+	if r.brnd() {
+		g.LPCutoffFreq = 1.0 - r.frnd(0.6)
 	}
 }
 
-func (g *Config) PresetBlip() {
+func (g *Config) PresetBlip(rng *rand.Rand) {
 	g.Reset()
-	g.Waveform = Waveform(rnd(1))
+	g.Seed = rng.Int63()
+	r := random{rng}
+	g.Waveform = Waveform(r.rnd(1))
 	if g.Waveform == WaveformSquare {
-		g.DutyCycle = frnd(0.6)
+		g.DutyCycle = r.frnd(0.6)
 	}
-	g.FreqStart = 0.2 + frnd(0.4)
+	g.FreqStart = 0.2 + r.frnd(0.4)
 	g.EnvelopeAttack = 0.0
-	g.EnvelopeSustain = 0.1 + frnd(0.1)
-	g.EnvelopeDecay = frnd(0.2)
+	g.EnvelopeSustain = 0.1 + r.frnd(0.1)
+	g.EnvelopeDecay = r.frnd(0.2)
 	g.HPCutoffFreq = 0.1
 }
 
-func (g *Config) Mutate() {
-	if brnd() {
-		g.FreqStart += frnd(0.1) - 0.05
+func (g *Config) Mutate(rng *rand.Rand) {
+	r := random{rng}
+	if r.brnd() {
+		g.FreqStart += r.frnd(0.1) - 0.05
+	}
+	//		if r.brnd() {g.FreqMinCutoff+=r.frnd(0.1)-0.05;       }
+	if r.brnd() {
+		g.FreqSlide += r.frnd(0.1) - 0.05
+	}
+	if r.brnd() {
+		g.FreqDeltaSlide += r.frnd(0.1) - 0.05
 	}
-	//		if brnd() {g.FreqMinCutoff+=frnd(0.1)-0.05;       }
-	if brnd() {
-		g.FreqSlide += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.DutyCycle += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.FreqDeltaSlide += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.DutyCycleSweep += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.DutyCycle += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.VibDepth += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.DutyCycleSweep += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.VibSpeed += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.VibDepth += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.VibDelay += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.VibSpeed += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.EnvelopeAttack += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.VibDelay += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.EnvelopeSustain += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.EnvelopeAttack += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.EnvelopeDecay += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.EnvelopeSustain += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.EnvelopeSustainPunch += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.EnvelopeDecay += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.LPResonance += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.EnvelopeSustainPunch += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.LPCutoffFreq += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.LPResonance += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.LPCutoffSweep += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.LPCutoffFreq += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.HPCutoffFreq += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.LPCutoffSweep += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.HPCutoffSweep += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.HPCutoffFreq += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.PhaserOffset += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.HPCutoffSweep += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.PhaserSweep += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.PhaserOffset += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.RepeatRate += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.PhaserSweep += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.ArpChangeSpeed += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.RepeatRate += frnd(0.1) - 0.05
+	if r.brnd() {
+		g.ArpFreqMult += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.ArpChangeSpeed += frnd(0.1) - 0.05
+	// Only mutate the lo-fi effects if they are in use, so that Mutate
+	// doesn't add them to clean sounds.
+	if g.BitCrush != 0 && r.brnd() {
+		g.BitCrush += r.frnd(0.1) - 0.05
 	}
-	if brnd() {
-		g.ArpFreqMult += frnd(0.1) - 0.05
+	if g.BitCrushSweep != 0 && r.brnd() {
+		g.BitCrushSweep += r.frnd(0.1) - 0.05
+	}
+	if g.Downsample != 0 && r.brnd() {
+		g.Downsample += r.frnd(0.1) - 0.05
+	}
+	if g.DownsampleSweep != 0 && r.brnd() {
+		g.DownsampleSweep += r.frnd(0.1) - 0.05
 	}
 }
 
-func (g *Config) Randomize() {
-	g.FreqStart = math.Pow(frnd(2.0)-1.0, 2.0)
-	if brnd() {
-		g.FreqStart = math.Pow(frnd(2.0)-1.0, 3.0) + 0.5
+func (g *Config) Randomize(rng *rand.Rand) {
+	g.Seed = rng.Int63()
+	r := random{rng}
+	g.FreqStart = math.Pow(r.frnd(2.0)-1.0, 2.0)
+	if r.brnd() {
+		g.FreqStart = math.Pow(r.frnd(2.0)-1.0, 3.0) + 0.5
 	}
 	g.FreqMinCutoff = 0.0
-	g.FreqSlide = math.Pow(frnd(2.0)-1.0, 5.0)
+	g.FreqSlide = math.Pow(r.frnd(2.0)-1.0, 5.0)
 	if g.FreqStart > 0.7 && g.FreqSlide > 0.2 {
 		g.FreqSlide = -g.FreqSlide
 	}
 	if g.FreqStart < 0.2 && g.FreqSlide < -0.05 {
 		g.FreqSlide = -g.FreqSlide
 	}
-	g.FreqDeltaSlide = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.DutyCycle = frnd(2.0) - 1.0
-	g.DutyCycleSweep = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.VibDepth = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.VibSpeed = frnd(2.0) - 1.0
-	g.VibDelay = frnd(2.0) - 1.0
-	g.EnvelopeAttack = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.EnvelopeSustain = math.Pow(frnd(2.0)-1.0, 2.0)
-	g.EnvelopeDecay = frnd(2.0) - 1.0
-	g.EnvelopeSustainPunch = math.Pow(frnd(0.8), 2.0)
+	g.FreqDeltaSlide = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.DutyCycle = r.frnd(2.0) - 1.0
+	g.DutyCycleSweep = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.VibDepth = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.VibSpeed = r.frnd(2.0) - 1.0
+	g.VibDelay = r.frnd(2.0) - 1.0
+	g.EnvelopeAttack = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.EnvelopeSustain = math.Pow(r.frnd(2.0)-1.0, 2.0)
+	g.EnvelopeDecay = r.frnd(2.0) - 1.0
+	g.EnvelopeSustainPunch = math.Pow(r.frnd(0.8), 2.0)
 	if g.EnvelopeAttack+g.EnvelopeSustain+g.EnvelopeDecay < 0.2 {
-		g.EnvelopeSustain += 0.2 + frnd(0.3)
-		g.EnvelopeDecay += 0.2 + frnd(0.3)
+		g.EnvelopeSustain += 0.2 + r.frnd(0.3)
+		g.EnvelopeDecay += 0.2 + r.frnd(0.3)
 	}
-	g.LPResonance = frnd(2.0) - 1.0
-	g.LPCutoffFreq = 1.0 - math.Pow(frnd(1.0), 3.0)
-	g.LPCutoffSweep = math.Pow(frnd(2.0)-1.0, 3.0)
+	g.LPResonance = r.frnd(2.0) - 1.0
+	g.LPCutoffFreq = 1.0 - math.Pow(r.frnd(1.0), 3.0)
+	g.LPCutoffSweep = math.Pow(r.frnd(2.0)-1.0, 3.0)
 	if g.LPCutoffFreq < 0.1 && g.LPCutoffSweep < -0.05 {
 		g.LPCutoffSweep = -g.LPCutoffSweep
 	}
-	g.HPCutoffFreq = math.Pow(frnd(1.0), 5.0)
-	g.HPCutoffSweep = math.Pow(frnd(2.0)-1.0, 5.0)
-	g.PhaserOffset = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.PhaserSweep = math.Pow(frnd(2.0)-1.0, 3.0)
-	g.RepeatRate = frnd(2.0) - 1.0
-	g.ArpChangeSpeed = frnd(2.0) - 1.0
-	g.ArpFreqMult = frnd(2.0) - 1.0
+	g.HPCutoffFreq = math.Pow(r.frnd(1.0), 5.0)
+	g.HPCutoffSweep = math.Pow(r.frnd(2.0)-1.0, 5.0)
+	g.PhaserOffset = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.PhaserSweep = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	g.RepeatRate = r.frnd(2.0) - 1.0
+	g.ArpChangeSpeed = r.frnd(2.0) - 1.0
+	g.ArpFreqMult = r.frnd(2.0) - 1.0
+	g.BitCrush = 0.0
+	g.BitCrushSweep = 0.0
+	if r.rnd(3) == 0 {
+		g.BitCrush = r.frnd(1.0)
+		g.BitCrushSweep = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	}
+	g.Downsample = 0.0
+	g.DownsampleSweep = 0.0
+	if r.rnd(3) == 0 {
+		g.Downsample = math.Pow(r.frnd(1.0), 2.0)
+		g.DownsampleSweep = math.Pow(r.frnd(2.0)-1.0, 3.0)
+	}
 }
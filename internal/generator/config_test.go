@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPresetsAreReproducible(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset func(*Config, *rand.Rand)
+	}{
+		{name: "Pickup", preset: (*Config).PresetPickup},
+		{name: "Laser", preset: (*Config).PresetLaser},
+		{name: "Explosion", preset: (*Config).PresetExplosion},
+		{name: "Powerup", preset: (*Config).PresetPowerup},
+		{name: "Hit", preset: (*Config).PresetHit},
+		{name: "Jump", preset: (*Config).PresetJump},
+		{name: "Blip", preset: (*Config).PresetBlip},
+		{name: "Mutate", preset: (*Config).Mutate},
+		{name: "Randomize", preset: (*Config).Randomize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c1 := NewConfig()
+			tt.preset(c1, NewRand(1234))
+			c2 := NewConfig()
+			tt.preset(c2, NewRand(1234))
+			if !reflect.DeepEqual(c1, c2) {
+				t.Errorf("same seed gave %v and %v", c1, c2)
+			}
+		})
+	}
+}
+
+func TestInitFromJsonVibDelay(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want float64
+	}{
+		{name: "Legacy key", json: `{"vid_delay": 0.25}`, want: 0.25},
+		{name: "Current key", json: `{"vib_delay": 0.5}`, want: 0.5},
+		{name: "Current key wins", json: `{"vid_delay": 0.25, "vib_delay": 0.5}`, want: 0.5},
+		{name: "Missing", json: `{"base_freq": 0.5}`, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			if err := c.InitFromJson([]byte(tt.json)); err != nil {
+				t.Fatalf("InitFromJson() failed: %s", err)
+			}
+			if c.VibDelay != tt.want {
+				t.Errorf("VibDelay = %v, want %v", c.VibDelay, tt.want)
+			}
+		})
+	}
+
+	c := NewConfig()
+	c.VibDelay = 0.75
+	got := NewConfig()
+	if err := got.InitFromJson(c.ToJson()); err != nil || !reflect.DeepEqual(got, c) {
+		t.Errorf("round trip gave %+v, %v, want %+v", got, err, c)
+	}
+}
+
+func TestInitFromJsonResetsMissingValues(t *testing.T) {
+	c := NewConfig()
+	c.Pan = -1
+	c.StereoSpread = 0.5
+	c.BitCrush = 0.8
+	c.Downsample = 0.4
+	c.CompRatio = 1
+	// Written before stereo, lo-fi effects and compression were added
+	old := `{"waveform": 1, "volume": 0.5, "base_freq": 0.5, "env_sustain": 0.2, "env_decay": 0.3}`
+	if err := c.InitFromJson([]byte(old)); err != nil {
+		t.Fatalf("InitFromJson() failed: %s", err)
+	}
+	want := NewConfig()
+	if err := json.Unmarshal([]byte(old), want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	if c.Pan != 0 || c.StereoSpread != 0 || c.BitCrush != 0 || c.Downsample != 0 || c.CompRatio != 0 {
+		t.Errorf("InitFromJson() kept values that are missing in the file: %+v", c)
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	// The frequency is proportional to f²+0.001 for a slider value of f.
+	freq := func(f float64) float64 { return f*f + 0.001 }
+	tests := []struct {
+		name      string
+		semitones float64
+		ratio     float64
+	}{
+		{name: "Octave up", semitones: 12, ratio: 2},
+		{name: "Octave down", semitones: -12, ratio: 0.5},
+		{name: "Fifth up", semitones: 7, ratio: math.Pow(2, 7.0/12)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			c.FreqStart = 0.4
+			c.FreqMinCutoff = 0.2
+			c.Transpose(tt.semitones)
+			if got := freq(c.FreqStart) / freq(0.4); math.Abs(got-tt.ratio) > 1e-9 {
+				t.Errorf("start frequency changed by %f, want %f", got, tt.ratio)
+			}
+			if got := freq(c.FreqMinCutoff) / freq(0.2); math.Abs(got-tt.ratio) > 1e-9 {
+				t.Errorf("cutoff frequency changed by %f, want %f", got, tt.ratio)
+			}
+		})
+	}
+
+	c := NewConfig()
+	c.FreqStart = 0.01
+	c.Transpose(-24)
+	if c.FreqStart != 0 || c.FreqMinCutoff != 0 {
+		t.Errorf("got FreqStart %f and FreqMinCutoff %f, want 0", c.FreqStart, c.FreqMinCutoff)
+	}
+}
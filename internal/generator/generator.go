@@ -20,11 +20,27 @@ package generator
 
 import (
 	"math"
+	"math/rand"
+	"time"
 )
 
-type Generator struct {
+// voice synthesizes one channel of a sound.
+type voice struct {
 	cfg Config
 
+	// Stereo adjustments of this channel
+	period_mult  float64
+	phaser_delay int
+	done         bool
+	// Whether to use band-limited oscillators and decimation
+	bandLimited bool
+	// Supersamples per sample
+	oversampling int
+	// Samples and supersamples per sample and supersample at the default
+	// rate and oversampling. All of sfxr's timing is in those.
+	time_scale  float64
+	super_scale float64
+
 	phase         int
 	fperiod       float64
 	fmaxperiod    float64
@@ -33,6 +49,12 @@ type Generator struct {
 	period        int
 	square_duty   float64
 	square_slide  float64
+	min_period    int
+	bl_phase      float64
+	bl_dt         float64
+	dec_filter    []float64
+	dec_buffer    []float64
+	dec_pos       int
 	env_stage     int
 	env_time      int
 	env_length    [3]int
@@ -40,25 +62,66 @@ type Generator struct {
 	fphase        float64
 	fdphase       float64
 	iphase        int
-	phaser_buffer [1024]float64
+	max_iphase    int
+	phaser_buffer []float64
 	ipp           int
 	noise_buffer  [32]float64
+	pink_state    [3]float64
+	brown_state   float64
+	lfsr          uint16
 	fltp          float64
 	fltdp         float64
 	fltw          float64
 	fltw_d        float64
+	fltw_max      float64
 	fltdmp        float64
 	fltphp        float64
 	flthp         float64
 	flthp_d       float64
+	flthp_min     float64
+	flthp_max     float64
+	crush_bits    float64
+	crush_slide   float64
+	ds_factor     float64
+	ds_factor_d   float64
+	ds_time       float64
+	ds_sample     float64
+	comp_env      float64
+	comp_attack   float64
+	comp_release  float64
 	vib_phase     float64
 	vib_speed     float64
 	vib_amp       float64
+	vib_time      int
+	vib_delay     int
 	rep_time      int
 	rep_limit     int
 	arp_time      int
 	arp_limit     int
 	arp_mod       float64
+	rng           random
+}
+
+// Generator renders a sound. Stereo sounds are rendered as interleaved
+// frames, left channel first.
+type Generator struct {
+	cfg    Config
+	voices []*voice
+	// Volume of each channel
+	gains []float64
+	// Sample rate in Hz
+	sampleRate int
+	// Latest sample of each voice, kept to avoid allocations in Next
+	samples []float64
+
+	// Frames to generate at most, or 0 for no limit
+	maxFrames int
+	// Frames generated since the last Reset
+	frames int
+
+	started   bool
+	done      bool
+	truncated bool
 }
 
 /*
@@ -90,241 +153,620 @@ sed -e 's/p_hpf_ramp/HPCutoffSweep/g' \
 
 */
 
-func (g *Generator) init() {
-	g.phase = 0
+func (v *voice) init() {
+	// Rates and lengths are scaled so that sounds keep their pitch and
+	// duration at any sample rate and oversampling.
+	ts, ss := v.time_scale, v.super_scale
+
+	v.phase = 0
+	// At low rates, 8 supersamples scale down to less than one, but the
+	// period must stay positive.
+	v.min_period = int(8 * ss)
+	if v.min_period < 1 {
+		v.min_period = 1
+	}
+	v.bl_phase = 0.0
+	for i := range v.dec_buffer {
+		v.dec_buffer[i] = 0
+	}
+	v.dec_pos = 0
 	// reset filter
-	g.fltp = 0.0
-	g.fltdp = 0.0
-	g.fltw = math.Pow(g.cfg.LPCutoffFreq, 3.0) * 0.1
-	g.fltw_d = 1.0 + g.cfg.LPCutoffSweep*0.0001
-	g.fltdmp = 5.0 / (1.0 + math.Pow(g.cfg.LPResonance, 2.0)*20.0) * (0.01 + g.fltw)
-	if g.fltdmp > 0.8 {
-		g.fltdmp = 0.8
-	}
-	g.fltphp = 0.0
-	g.flthp = math.Pow(g.cfg.HPCutoffFreq, 2.0) * 0.1
-	g.flthp_d = 1.0 + g.cfg.HPCutoffSweep*0.0003
+	v.fltp = 0.0
+	v.fltdp = 0.0
+	v.fltw = math.Pow(v.cfg.LPCutoffFreq, 3.0) * 0.1
+	v.fltw_d = 1.0 + v.cfg.LPCutoffSweep*0.0001/ss
+	v.fltdmp = 5.0 / (1.0 + math.Pow(v.cfg.LPResonance, 2.0)*20.0) * (0.01 + v.fltw)
+	if v.fltdmp > 0.8 {
+		v.fltdmp = 0.8
+	}
+	// The low-pass filter is a resonator, so its frequency goes with the
+	// square root of fltw. With fewer supersamples, the filters would get
+	// unstable beyond sfxr's limits.
+	v.fltw /= ss * ss
+	v.fltw_max = math.Min(0.1, 0.1/(ss*ss))
+	v.fltdmp = math.Min(0.8, v.fltdmp/ss)
+	v.fltphp = 0.0
+	v.flthp = math.Pow(v.cfg.HPCutoffFreq, 2.0) * 0.1 / ss
+	v.flthp_d = 1.0 + v.cfg.HPCutoffSweep*0.0003/ts
+	v.flthp_min = 0.00001 / ss
+	v.flthp_max = math.Min(0.1, 0.1/ss)
+
+	// reset lo-fi effects
+	v.crush_bits = maxCrushBits - v.cfg.BitCrush*(maxCrushBits-1)
+	v.crush_slide = -v.cfg.BitCrushSweep * 0.0003 / ts
+	v.ds_factor = (1.0 + math.Pow(v.cfg.Downsample, 2.0)*(maxDownsample/2-1)) * ts
+	v.ds_factor_d = 1.0 + v.cfg.DownsampleSweep*0.0001/ts
+	v.ds_time = 0.0
+	v.ds_sample = 0.0
+	v.comp_env = 0.0
 
 	// reset vibrato
-	g.vib_phase = 0.0
-	g.vib_speed = math.Pow(g.cfg.VibSpeed, 2.0) * 0.01
-	g.vib_amp = g.cfg.VibDepth * 0.5
+	v.vib_phase = 0.0
+	v.vib_speed = math.Pow(v.cfg.VibSpeed, 2.0) * 0.01 / ts
+	v.vib_amp = v.cfg.VibDepth * 0.5
+	v.vib_time = 0
+	v.vib_delay = int(v.cfg.VibDelay * v.cfg.VibDelay * 100000.0 * ts)
 
 	// reset envelope
-	g.env_vol = 0.0
-	g.env_stage = 0
-	g.env_time = 0
-	g.env_length[0] = (int)(g.cfg.EnvelopeAttack * g.cfg.EnvelopeAttack * 100000.0)
-	g.env_length[1] = (int)(g.cfg.EnvelopeSustain * g.cfg.EnvelopeSustain * 100000.0)
-	g.env_length[2] = (int)(g.cfg.EnvelopeDecay * g.cfg.EnvelopeDecay * 100000.0)
+	v.env_vol = 0.0
+	v.env_stage = 0
+	v.env_time = 0
+	v.env_length[0] = (int)(v.cfg.EnvelopeAttack * v.cfg.EnvelopeAttack * 100000.0 * ts)
+	v.env_length[1] = (int)(v.cfg.EnvelopeSustain * v.cfg.EnvelopeSustain * 100000.0 * ts)
+	v.env_length[2] = (int)(v.cfg.EnvelopeDecay * v.cfg.EnvelopeDecay * 100000.0 * ts)
 
-	g.fphase = math.Pow(g.cfg.PhaserOffset, 2.0) * 1020.0
-	if g.cfg.PhaserOffset < 0.0 {
-		g.fphase = -g.fphase
+	v.fphase = math.Pow(v.cfg.PhaserOffset, 2.0) * 1020.0 * ss
+	if v.cfg.PhaserOffset < 0.0 {
+		v.fphase = -v.fphase
 	}
-	g.fdphase = math.Pow(g.cfg.PhaserSweep, 2.0) * 1.0
-	if g.cfg.PhaserSweep < 0 {
-		g.fdphase = -g.fdphase
+	v.fdphase = math.Pow(v.cfg.PhaserSweep, 2.0) * 1.0 * ss / ts
+	if v.cfg.PhaserSweep < 0 {
+		v.fdphase = -v.fdphase
 	}
-	g.iphase = int(math.Abs(g.fphase)) // abs((int)fphase)
-	g.ipp = 0
-	for i := 0; i < 1024; i++ {
-		g.phaser_buffer[i] = 0
+	v.iphase = int(math.Abs(v.fphase)) + v.phaser_delay // abs((int)fphase)
+	v.ipp = 0
+	for i := range v.phaser_buffer {
+		v.phaser_buffer[i] = 0
 	}
 
-	for i := 0; i < 32; i++ {
-		g.noise_buffer[i] = frnd(2.0) - 1.0
-	}
+	v.rng = random{rand.New(rand.NewSource(v.cfg.Seed))}
+	v.pink_state = [3]float64{}
+	v.brown_state = 0
+	v.lfsr = 1
+	v.fillNoiseBuffer()
 
-	g.rep_time = 0
-	g.rep_limit = int(math.Pow(1.0-g.cfg.RepeatRate, 2.0)*20000 + 32)
-	if g.cfg.RepeatRate == 0.0 {
-		g.rep_limit = 0
+	v.rep_time = 0
+	v.rep_limit = int((math.Pow(1.0-v.cfg.RepeatRate, 2.0)*20000 + 32) * ts)
+	if v.cfg.RepeatRate == 0.0 {
+		v.rep_limit = 0
 	}
 }
 
-func (g *Generator) initForRepeat() {
-	cfg := g.cfg
-	g.fperiod = 100.0 / (cfg.FreqStart*cfg.FreqStart + 0.001)
-	g.period = int(g.fperiod)
-	g.fmaxperiod = 100.0 / (cfg.FreqMinCutoff*cfg.FreqMinCutoff + 0.001)
-	g.fslide = 1.0 - math.Pow(cfg.FreqSlide, 3)*0.01
-	g.fdslide = -math.Pow(cfg.FreqDeltaSlide, 3) * 0.000001
+func (v *voice) initForRepeat() {
+	cfg := v.cfg
+	ts, ss := v.time_scale, v.super_scale
+	v.fperiod = 100.0 / (cfg.FreqStart*cfg.FreqStart + 0.001) * v.period_mult * ss
+	v.period = int(v.fperiod)
+	v.fmaxperiod = 100.0 / (cfg.FreqMinCutoff*cfg.FreqMinCutoff + 0.001) * v.period_mult * ss
+	v.fslide = 1.0 - math.Pow(cfg.FreqSlide, 3)*0.01/ts
+	v.fdslide = -math.Pow(cfg.FreqDeltaSlide, 3) * 0.000001 / (ts * ts)
 
-	g.square_duty = 0.5 - cfg.DutyCycle*0.5
-	g.square_slide = -cfg.DutyCycleSweep * 0.00005
+	v.square_duty = 0.5 - cfg.DutyCycle*0.5
+	v.square_slide = -cfg.DutyCycleSweep * 0.00005 / ts
 
 	if cfg.ArpFreqMult >= 0 {
-		g.arp_mod = 1.0 - math.Pow(cfg.ArpFreqMult, 2.0)*0.9
+		v.arp_mod = 1.0 - math.Pow(cfg.ArpFreqMult, 2.0)*0.9
 	} else {
-		g.arp_mod = 1.0 + math.Pow(cfg.ArpFreqMult, 2.0)*10.0
+		v.arp_mod = 1.0 + math.Pow(cfg.ArpFreqMult, 2.0)*10.0
 	}
-	g.arp_time = 0
-	g.arp_limit = int(math.Pow(1.0-cfg.ArpChangeSpeed, 2.0)*20000 + 32)
+	v.arp_time = 0
+	v.arp_limit = int((math.Pow(1.0-cfg.ArpChangeSpeed, 2.0)*20000 + 32) * ts)
 	if cfg.ArpChangeSpeed == 1.0 {
-		g.arp_limit = 0
+		v.arp_limit = 0
+	}
+
+}
+
+// Bits left by a bit crush of 0
+const maxCrushBits = 16
+
+// Largest sample the bit crush expects. The phaser doubles the signal, so
+// samples reach 2 before the volume is applied.
+const crushFullScale = 2.0
+
+// Largest number of samples a sample is held for by the downsampler, at the
+// default sample rate
+const maxDownsample = 64
+
+// Largest relative change of the period of the left and right channel
+const maxStereoDetune = 0.01
+
+// Largest additional phaser delay of the right channel, in supersamples at
+// the default sample rate and oversampling
+const maxStereoSpread = 512
+
+// DefaultSampleRate is sfxr's sample rate in Hz.
+const DefaultSampleRate = 44100
+
+// DefaultOversampling is the number of supersamples sfxr averages per
+// sample.
+const DefaultOversampling = 8
+
+// DefaultMaxDuration is where New cuts sounds off. Sounds made with the
+// sliders are at most about 7 seconds long, but configurations read from
+// files can ask for much longer ones.
+const DefaultMaxDuration = 10 * time.Second
+
+// Options control how sounds are generated.
+type Options struct {
+	// MaxDuration cuts sounds off after this long. 0 means no limit.
+	MaxDuration time.Duration
+	// BandLimited reduces aliasing with band-limited square and sawtooth
+	// waves and a low-pass filter that replaces the averaging of the
+	// supersamples. Without it, sounds are the same as in sfxr.
+	BandLimited bool
+	// SampleRate in Hz. 0 means DefaultSampleRate.
+	SampleRate int
+	// Oversampling is the number of supersamples per sample. More of them
+	// reduce aliasing, but take longer to compute. 0 means
+	// DefaultOversampling.
+	Oversampling int
+}
+
+func (o Options) sampleRate() int {
+	if o.SampleRate <= 0 {
+		return DefaultSampleRate
 	}
+	return o.SampleRate
+}
 
+func (o Options) oversampling() int {
+	if o.Oversampling <= 0 {
+		return DefaultOversampling
+	}
+	return o.Oversampling
 }
 
+// New returns a generator for cfg that stops after DefaultMaxDuration.
 func New(cfg *Config) *Generator {
+	return NewWithOptions(cfg, Options{MaxDuration: DefaultMaxDuration})
+}
+
+// NewWithOptions returns a generator for cfg.
+func NewWithOptions(cfg *Config, opts Options) *Generator {
 	g := &Generator{
-		cfg: *cfg,
+		cfg:        *cfg,
+		sampleRate: opts.sampleRate(),
+		maxFrames:  int(opts.MaxDuration.Seconds() * float64(opts.sampleRate())),
+	}
+	g.voices = []*voice{newVoice(cfg, opts)}
+	if cfg.StereoSpread != 0 || cfg.StereoDetune != 0 {
+		// The channels differ, so each one needs its own voice.
+		detune := cfg.StereoDetune * maxStereoDetune
+		g.voices[0].period_mult = 1 + detune
+		right := newVoice(cfg, opts)
+		right.period_mult = 1 - detune
+		right.phaser_delay = int(cfg.StereoSpread * maxStereoSpread * right.super_scale)
+		g.voices = append(g.voices, right)
+	}
+	g.samples = make([]float64, len(g.voices))
+	g.gains = []float64{1}
+	if cfg.Channels() == 2 {
+		g.gains = []float64{math.Min(1, 1-cfg.Pan), math.Min(1, 1+cfg.Pan)}
 	}
 	return g
 }
 
+// newVoice returns a voice for cfg that plays at the sample rate and
+// oversampling of opts.
+func newVoice(cfg *Config, opts Options) *voice {
+	rate, oversampling := opts.sampleRate(), opts.oversampling()
+	v := &voice{
+		cfg:          *cfg,
+		period_mult:  1,
+		bandLimited:  opts.BandLimited,
+		oversampling: oversampling,
+		time_scale:   float64(rate) / DefaultSampleRate,
+		super_scale:  float64(rate*oversampling) / (DefaultSampleRate * DefaultOversampling),
+	}
+	// The phaser delays by up to 1023 supersamples at the default rate.
+	v.max_iphase = int(1023 * v.super_scale)
+	size := 1024
+	for size <= v.max_iphase {
+		size *= 2
+	}
+	v.phaser_buffer = make([]float64, size)
+	if opts.BandLimited {
+		v.dec_filter = decimationFilter(oversampling)
+		v.dec_buffer = make([]float64, len(v.dec_filter))
+	}
+	// The compressor follows rising levels within about 1 ms, and falling
+	// ones within about 100 ms.
+	v.comp_attack = 1.0 - math.Exp(-1000.0/float64(rate))
+	v.comp_release = 1.0 - math.Exp(-10.0/float64(rate))
+	return v
+}
+
+// Channels returns the number of channels of the sound.
+func (g *Generator) Channels() int {
+	return len(g.gains)
+}
+
+// SampleRate returns the sample rate of the sound in Hz.
+func (g *Generator) SampleRate() int {
+	return g.sampleRate
+}
+
 const masterVolume = 0.05
 
-func (g *Generator) Generate() []float64 {
-	g.init()
-	g.initForRepeat()
+// Reset rewinds the generator to the beginning of the sound.
+func (g *Generator) Reset() {
+	for _, v := range g.voices {
+		v.init()
+		v.initForRepeat()
+		v.rep_time = 0
+		v.done = false
+	}
+	g.frames = 0
+	g.started = true
+	g.done = false
+	g.truncated = false
+}
 
-	g.rep_time = 0
-	var buffer []float64
+// Done reports whether the sound is finished.
+func (g *Generator) Done() bool {
+	return g.done
+}
 
-	for {
-		g.rep_time++
-		if g.rep_limit != 0 && g.rep_time >= g.rep_limit {
-			g.rep_time = 0
-			g.initForRepeat()
-		}
+// Duration returns the length of the sound generated since the last Reset.
+// Once the generator is done, it is the length of the whole sound.
+func (g *Generator) Duration() time.Duration {
+	return FramesDuration(g.frames, g.sampleRate)
+}
+
+// Truncated reports whether the sound was cut off at the maximum duration.
+func (g *Generator) Truncated() bool {
+	return g.truncated
+}
 
-		// frequency envelopes/arpeggios
-		g.arp_time++
-		if g.arp_limit != 0 && g.arp_time >= g.arp_limit {
-			g.arp_limit = 0
-			g.fperiod *= g.arp_mod
+// FramesDuration returns how long the given number of frames play at
+// sampleRate.
+func FramesDuration(frames, sampleRate int) time.Duration {
+	return time.Duration(frames) * time.Second / time.Duration(sampleRate)
+}
+
+// Next fills buf with the next samples and returns how many were written.
+// Only whole frames are written, so len(buf) should be a multiple of
+// Channels(). Fewer samples are only returned when the sound ends; after
+// that, Next returns 0 until the generator is Reset. Like io.Reader with an
+// empty buffer, Next also returns 0 without doing anything if buf is
+// shorter than one frame.
+func (g *Generator) Next(buf []float64) int {
+	channels := len(g.gains)
+	if len(buf) < channels {
+		return 0
+	}
+	if !g.started {
+		g.Reset()
+	}
+	samples := g.samples
+	n := 0
+	for n+channels <= len(buf) && !g.done {
+		if g.maxFrames > 0 && g.frames >= g.maxFrames {
+			g.done = true
+			g.truncated = true
+			break
 		}
-		g.fslide += g.fdslide
-		g.fperiod *= g.fslide
-		if g.fperiod > g.fmaxperiod {
-			g.fperiod = g.fmaxperiod
-			if g.cfg.FreqMinCutoff > 0.0 {
-				break
+		g.done = true
+		for i, v := range g.voices {
+			samples[i] = 0
+			if v.done {
+				continue
 			}
+			sample, ok := v.step()
+			if !ok {
+				// The other channel might still be playing
+				v.done = true
+				continue
+			}
+			samples[i] = sample
+			g.done = false
 		}
-		rfperiod := g.fperiod
-		if g.vib_amp > 0.0 {
-			g.vib_phase += g.vib_speed
-			rfperiod = g.fperiod * (1.0 + math.Sin(g.vib_phase)*g.vib_amp)
+		if g.done {
+			break
 		}
-		g.period = int(rfperiod)
-		if g.period < 8 {
-			g.period = 8
+		for c, gain := range g.gains {
+			buf[n+c] = samples[c%len(samples)] * gain
 		}
-		g.square_duty += g.square_slide
-		if g.square_duty < 0.0 {
-			g.square_duty = 0.0
+		n += channels
+		g.frames++
+	}
+	return n
+}
+
+// Generate renders the whole sound from the beginning. Stereo sounds are
+// returned as interleaved frames.
+func (g *Generator) Generate() []float64 {
+	g.Reset()
+
+	var buffer []float64
+	chunk := make([]float64, 4096)
+	for !g.done {
+		n := g.Next(chunk)
+		buffer = append(buffer, chunk[:n]...)
+	}
+	return buffer
+}
+
+// fillNoiseBuffer fills the noise buffer with white noise, which is
+// filtered for pink and brown noise.
+func (v *voice) fillNoiseBuffer() {
+	for i := 0; i < 32; i++ {
+		white := v.rng.frnd(2.0) - 1.0
+		switch v.cfg.Waveform {
+		case WaveformPinkNoise:
+			// Paul Kellett's economy filter
+			v.pink_state[0] = 0.99765*v.pink_state[0] + white*0.0990460
+			v.pink_state[1] = 0.96300*v.pink_state[1] + white*0.2965164
+			v.pink_state[2] = 0.57000*v.pink_state[2] + white*1.0526913
+			pink := v.pink_state[0] + v.pink_state[1] + v.pink_state[2] + white*0.1848
+			v.noise_buffer[i] = math.Max(-1, math.Min(1, pink*0.2))
+		case WaveformBrownNoise:
+			// Leaky integrator
+			v.brown_state = (v.brown_state + 0.02*white) / 1.02
+			v.noise_buffer[i] = math.Max(-1, math.Min(1, v.brown_state*3.5))
+		default:
+			v.noise_buffer[i] = white
+		}
+	}
+}
+
+// step computes the next sample. It returns false if the sound is finished.
+func (v *voice) step() (float64, bool) {
+	v.rep_time++
+	if v.rep_limit != 0 && v.rep_time >= v.rep_limit {
+		v.rep_time = 0
+		v.initForRepeat()
+	}
+
+	// frequency envelopes/arpeggios
+	v.arp_time++
+	if v.arp_limit != 0 && v.arp_time >= v.arp_limit {
+		v.arp_limit = 0
+		v.fperiod *= v.arp_mod
+	}
+	v.fslide += v.fdslide
+	v.fperiod *= v.fslide
+	if v.fperiod > v.fmaxperiod {
+		v.fperiod = v.fmaxperiod
+		if v.cfg.FreqMinCutoff > 0.0 {
+			return 0, false
 		}
-		if g.square_duty > 0.5 {
-			g.square_duty = 0.5
+	}
+	rfperiod := v.fperiod
+	if v.vib_amp > 0.0 {
+		if v.vib_time < v.vib_delay {
+			v.vib_time++
+		} else {
+			v.vib_phase += v.vib_speed
+			rfperiod = v.fperiod * (1.0 + math.Sin(v.vib_phase)*v.vib_amp)
 		}
+	}
+	v.period = int(rfperiod)
+	if v.period < v.min_period {
+		v.period = v.min_period
+	}
+	v.bl_dt = 1.0 / math.Max(rfperiod, float64(v.min_period))
+	v.square_duty += v.square_slide
+	if v.square_duty < 0.0 {
+		v.square_duty = 0.0
+	}
+	if v.square_duty > 0.5 {
+		v.square_duty = 0.5
+	}
+
+	// volume envelope
+	v.env_time++
+	if v.env_time > v.env_length[v.env_stage] {
+		v.env_time = 0
+		v.env_stage++
+		if v.env_stage == 3 {
+			return 0, false
+		}
+	}
+	switch v.env_stage {
+	case 0:
+		v.env_vol = float64(v.env_time) / float64(v.env_length[0])
+	case 1:
+		v.env_vol = 1.0 + math.Pow(1.0-float64(v.env_time)/float64(v.env_length[1]), 1.0)*2.0*v.cfg.EnvelopeSustainPunch
+	case 2:
+		v.env_vol = 1.0 - float64(v.env_time)/float64(v.env_length[2])
+	}
+
+	// phaser step
+	v.fphase += v.fdphase
+	v.iphase = int(math.Abs(v.fphase)) + v.phaser_delay
+	if v.iphase > v.max_iphase {
+		v.iphase = v.max_iphase
+	}
 
-		// volume envelope
-		g.env_time++
-		if g.env_time > g.env_length[g.env_stage] {
-			g.env_time = 0
-			g.env_stage++
-			if g.env_stage == 3 {
-				break
+	if v.flthp_d != 0.0 {
+		v.flthp *= v.flthp_d
+	}
+	if v.flthp < v.flthp_min {
+		v.flthp = v.flthp_min
+	}
+	if v.flthp > v.flthp_max {
+		v.flthp = v.flthp_max
+	}
+
+	ssample := 0.0
+	for si := 0; si < v.oversampling; si++ { // supersampling
+		sample := 0.0
+		v.phase++
+		if v.bandLimited {
+			// Unlike phase, this doesn't round the period.
+			v.bl_phase += v.bl_dt
+			if v.bl_phase >= 1.0 {
+				v.bl_phase -= 1.0
 			}
 		}
-		switch g.env_stage {
-		case 0:
-			g.env_vol = float64(g.env_time) / float64(g.env_length[0])
-		case 1:
-			g.env_vol = 1.0 + math.Pow(1.0-float64(g.env_time)/float64(g.env_length[1]), 1.0)*2.0*g.cfg.EnvelopeSustainPunch
-		case 2:
-			g.env_vol = 1.0 - float64(g.env_time)/float64(g.env_length[2])
+		if v.phase >= v.period {
+			v.phase %= v.period
+			switch v.cfg.Waveform {
+			case WaveformNoise, WaveformPinkNoise, WaveformBrownNoise:
+				v.fillNoiseBuffer()
+			case WaveformLFSRNoise:
+				feedback := (v.lfsr ^ v.lfsr>>1) & 1
+				v.lfsr = v.lfsr>>1 | feedback<<14
+			}
 		}
 
-		// phaser step
-		g.fphase += g.fdphase
-		g.iphase = int(math.Abs(g.fphase))
-		if g.iphase > 1023 {
-			g.iphase = 1023
+		// base waveform
+		fp := float64(v.phase) / float64(v.period)
+		switch v.cfg.Waveform {
+		case WaveformSquare:
+			if v.bandLimited {
+				sample = v.bandLimitedSquare()
+			} else if fp > v.square_duty {
+				sample = 0.5
+			} else {
+				sample = -0.5
+			}
+		case WaveformSawtooth:
+			if v.bandLimited {
+				sample = v.bandLimitedSawtooth()
+			} else {
+				sample = 1.0 - fp*2
+			}
+		case WaveformSine:
+			sample = math.Sin(fp * 2 * math.Pi)
+		case WaveformNoise, WaveformPinkNoise, WaveformBrownNoise:
+			sample = v.noise_buffer[v.phase*32/v.period]
+		case WaveformTriangle:
+			sample = 4*math.Abs(fp-0.5) - 1
+		case WaveformBreaker:
+			sample = math.Abs(1-fp*fp*2) - 1
+		case WaveformTan:
+			sample = math.Max(-1, math.Min(1, math.Tan(math.Pi*fp)))
+		case WaveformWhistle:
+			_, overtone := math.Modf(fp * 20)
+			sample = math.Sin(fp*2*math.Pi) + 0.25*math.Sin(overtone*2*math.Pi)
+		case WaveformLFSRNoise:
+			sample = float64(v.lfsr&1) - 0.5
 		}
 
-		if g.flthp_d != 0.0 {
-			g.flthp *= g.flthp_d
+		// lp filter
+		pp := v.fltp
+		v.fltw *= v.fltw_d
+		if v.fltw < 0.0 {
+			v.fltw = 0.0
 		}
-		if g.flthp < 0.00001 {
-			g.flthp = 0.00001
+		if v.fltw > v.fltw_max {
+			v.fltw = v.fltw_max
 		}
-		if g.flthp > 0.1 {
-			g.flthp = 0.1
+		if v.cfg.LPCutoffFreq != 1.0 {
+			v.fltdp += (sample - v.fltp) * v.fltw
+			v.fltdp -= v.fltdp * v.fltdmp
+		} else {
+			v.fltp = sample
+			v.fltdp = 0.0
 		}
+		v.fltp += v.fltdp
 
-		ssample := 0.0
-		for si := 0; si < 8; si++ { // 8x supersampling
-			sample := 0.0
-			g.phase++
-			if g.phase >= g.period {
-				g.phase %= g.period
-				if g.cfg.Waveform == WaveformNoise {
-					for i := 0; i < 32; i++ {
-						g.noise_buffer[i] = frnd(2.0) - 1.0
-					}
-				}
-			}
+		// hp filter
+		v.fltphp += v.fltp - pp
+		v.fltphp -= v.fltphp * v.flthp
+		sample = v.fltphp
 
-			// base waveform
-			fp := float64(g.phase) / float64(g.period)
-			switch g.cfg.Waveform {
-			case WaveformSquare:
-				if fp > g.square_duty {
-					sample = 0.5
-				} else {
-					sample = -0.5
-				}
-			case WaveformSawtooth:
-				sample = 1.0 - fp*2
-			case WaveformSine:
-				sample = math.Sin(fp * 2 * math.Pi)
-			case WaveformNoise:
-				sample = g.noise_buffer[g.phase*32/g.period]
-			}
+		// phaser
+		mask := len(v.phaser_buffer) - 1
+		v.phaser_buffer[v.ipp&mask] = sample
+		sample += v.phaser_buffer[(v.ipp-v.iphase+len(v.phaser_buffer))&mask]
+		v.ipp = (v.ipp + 1) & mask
+		// final accumulation and envelope application
+		if v.bandLimited {
+			v.decimate(sample * v.env_vol)
+		} else {
+			ssample += sample * v.env_vol
+		}
+	}
+	if v.bandLimited {
+		ssample = v.decimated()
+	} else {
+		ssample = ssample / float64(v.oversampling)
+	}
 
-			// lp filter
-			pp := g.fltp
-			g.fltw *= g.fltw_d
-			if g.fltw < 0.0 {
-				g.fltw = 0.0
-			}
-			if g.fltw > 0.1 {
-				g.fltw = 0.1
-			}
-			if g.cfg.LPCutoffFreq != 1.0 {
-				g.fltdp += (sample - g.fltp) * g.fltw
-				g.fltdp -= g.fltdp * g.fltdmp
-			} else {
-				g.fltp = sample
-				g.fltdp = 0.0
-			}
-			g.fltp += g.fltdp
-
-			// hp filter
-			g.fltphp += g.fltp - pp
-			g.fltphp -= g.fltphp * g.flthp
-			sample = g.fltphp
-
-			// phaser
-			g.phaser_buffer[g.ipp&1023] = sample
-			sample += g.phaser_buffer[(g.ipp-g.iphase+1024)&1023]
-			g.ipp = (g.ipp + 1) & 1023
-			// final accumulation and envelope application
-			ssample += sample * g.env_vol
+	// sample rate reduction
+	if v.cfg.Downsample != 0.0 || v.cfg.DownsampleSweep != 0.0 {
+		v.ds_factor *= v.ds_factor_d
+		if v.ds_factor < v.time_scale {
+			v.ds_factor = v.time_scale
+		}
+		if v.ds_factor > maxDownsample*v.time_scale {
+			v.ds_factor = maxDownsample * v.time_scale
 		}
-		ssample = ssample / 8 * masterVolume
-		ssample *= 2.0 * g.cfg.Volume
+		if v.ds_time <= 0.0 {
+			v.ds_time += v.ds_factor
+			v.ds_sample = ssample
+		}
+		v.ds_time -= 1.0
+		ssample = v.ds_sample
+	}
 
-		if ssample > 1.0 {
-			ssample = 1.0
-		} else if ssample < -1.0 {
-			ssample = -1.0
+	// bit crush
+	if v.cfg.BitCrush != 0.0 || v.cfg.BitCrushSweep != 0.0 {
+		v.crush_bits += v.crush_slide
+		if v.crush_bits < 1.0 {
+			v.crush_bits = 1.0
 		}
-		buffer = append(buffer, ssample)
+		if v.crush_bits > maxCrushBits {
+			v.crush_bits = maxCrushBits
+		}
+		steps := math.Pow(2.0, v.crush_bits-1) / crushFullScale
+		ssample = math.Floor(ssample*steps+0.5) / steps
 	}
-	return buffer
+
+	ssample *= masterVolume
+	ssample *= 2.0 * v.cfg.Volume
+
+	if v.cfg.CompRatio != 0.0 || v.cfg.CompMakeup != 0.0 {
+		ssample = softClip(v.compress(ssample))
+	} else if ssample > 1.0 {
+		ssample = 1.0
+	} else if ssample < -1.0 {
+		ssample = -1.0
+	}
+	return ssample, true
+}
+
+// compress applies the compressor and its makeup gain to sample.
+func (v *voice) compress(sample float64) float64 {
+	level := math.Abs(sample)
+	if level > v.comp_env {
+		v.comp_env += (level - v.comp_env) * v.comp_attack
+	} else {
+		v.comp_env += (level - v.comp_env) * v.comp_release
+	}
+
+	gain := math.Pow(10.0, v.cfg.CompMakeup*24.0/20.0)
+	threshold := math.Pow(10.0, (v.cfg.CompThreshold-1.0)*40.0/20.0)
+	if v.comp_env > threshold {
+		// Above the threshold, the level only grows with the 1/ratio'th power.
+		gain *= math.Pow(v.comp_env/threshold, -v.cfg.CompRatio)
+	}
+	return sample * gain
+}
+
+// Samples up to softClipKnee pass softClip unchanged.
+const softClipKnee = 0.8
+
+// softClip limits sample to ±1 without the harsh corners of clipping.
+func softClip(sample float64) float64 {
+	level := math.Abs(sample)
+	if level <= softClipKnee {
+		return sample
+	}
+	level = softClipKnee + (1.0-softClipKnee)*math.Tanh((level-softClipKnee)/(1.0-softClipKnee))
+	return math.Copysign(level, sample)
 }
@@ -0,0 +1,488 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetExplosion(NewRand(42))
+
+	s1 := New(cfg).Generate()
+	s2 := New(cfg).Generate()
+	if len(s1) == 0 {
+		t.Fatalf("Generate() returned no samples")
+	}
+	if !reflect.DeepEqual(s1, s2) {
+		t.Errorf("Generate() is not deterministic for seed %d", cfg.Seed)
+	}
+
+	cfg.Seed++
+	if s3 := New(cfg).Generate(); reflect.DeepEqual(s1, s3) {
+		t.Errorf("Generate() ignores the seed")
+	}
+}
+
+func TestNextMatchesGenerate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetLaser(NewRand(7))
+	want := New(cfg).Generate()
+
+	g := New(cfg)
+	var got []float64
+	buf := make([]float64, 100)
+	for !g.Done() {
+		n := g.Next(buf)
+		got = append(got, buf[:n]...)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Next() produced %d samples, Generate() %d", len(got), len(want))
+	}
+	if n := g.Next(buf); n != 0 {
+		t.Errorf("Next() after end = %d, want 0", n)
+	}
+}
+
+func TestVibratoDelay(t *testing.T) {
+	cfg := NewConfig()
+	cfg.VibDepth = 0.5
+	cfg.VibSpeed = 0.5
+	plain := NewConfig()
+	delayed := *cfg
+	delayed.VibDelay = 0.1 // 1000 samples
+
+	s1 := New(plain).Generate()
+	s2 := New(&delayed).Generate()
+	if !reflect.DeepEqual(s1[:1000], s2[:1000]) {
+		t.Errorf("vibrato started before the delay")
+	}
+	if reflect.DeepEqual(s1[1000:2000], s2[1000:2000]) {
+		t.Errorf("vibrato didn't start after the delay")
+	}
+}
+
+func TestStereo(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetLaser(NewRand(3))
+	mono := New(cfg).Generate()
+
+	tests := []struct {
+		name      string
+		pan       float64
+		spread    float64
+		detune    float64
+		wantLeft  func(i int) float64
+		wantRight func(i int) float64
+	}{
+		{
+			name:      "pan left",
+			pan:       -0.5,
+			wantLeft:  func(i int) float64 { return mono[i] },
+			wantRight: func(i int) float64 { return mono[i] * 0.5 },
+		},
+		{
+			name:      "pan right",
+			pan:       1,
+			wantLeft:  func(i int) float64 { return 0 },
+			wantRight: func(i int) float64 { return mono[i] },
+		},
+		{
+			name:     "spread",
+			spread:   0.5,
+			wantLeft: func(i int) float64 { return mono[i] },
+		},
+		{
+			name:   "detune",
+			detune: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stereo := *cfg
+			stereo.Pan, stereo.StereoSpread, stereo.StereoDetune = tt.pan, tt.spread, tt.detune
+			g := New(&stereo)
+			if g.Channels() != 2 {
+				t.Fatalf("Channels() = %d, want 2", g.Channels())
+			}
+			got := g.Generate()
+			if len(got)%2 != 0 {
+				t.Fatalf("Generate() returned %d samples, want whole frames", len(got))
+			}
+			left, right := make([]float64, len(got)/2), make([]float64, len(got)/2)
+			for i := range left {
+				left[i], right[i] = got[2*i], got[2*i+1]
+			}
+			for i := range mono {
+				if tt.wantLeft != nil && left[i] != tt.wantLeft(i) {
+					t.Fatalf("left[%d] = %f, want %f", i, left[i], tt.wantLeft(i))
+				}
+				if tt.wantRight != nil && right[i] != tt.wantRight(i) {
+					t.Fatalf("right[%d] = %f, want %f", i, right[i], tt.wantRight(i))
+				}
+			}
+			if tt.wantRight == nil && reflect.DeepEqual(left, right) {
+				t.Errorf("channels are identical")
+			}
+		})
+	}
+}
+
+func TestNextWritesWholeFrames(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Pan = 0.3
+	g := New(cfg)
+	buf := make([]float64, 101)
+	if n := g.Next(buf); n != 100 {
+		t.Errorf("Next() = %d, want 100", n)
+	}
+}
+
+func TestNextShortBuffer(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Pan = 0.3
+	g := New(cfg)
+	for _, buf := range [][]float64{nil, make([]float64, 1)} {
+		if n := g.Next(buf); n != 0 {
+			t.Errorf("Next() with %d samples = %d, want 0", len(buf), n)
+		}
+	}
+	// The sound still starts from the beginning.
+	want := New(cfg).Generate()
+	got := make([]float64, len(want))
+	if n := g.Next(got); n != len(want) || !reflect.DeepEqual(got, want) {
+		t.Errorf("Next() after short buffers differs from Generate()")
+	}
+}
+
+func TestNextDoesNotAllocate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.StereoDetune = 0.3
+	g := New(cfg)
+	buf := make([]float64, 64)
+	if allocs := testing.AllocsPerRun(10, func() { g.Next(buf) }); allocs != 0 {
+		t.Errorf("Next() made %.0f allocations, want 0", allocs)
+	}
+}
+
+func TestMonoByDefault(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetBlip(NewRand(1))
+	if g := New(cfg); g.Channels() != 1 {
+		t.Errorf("Channels() = %d, want 1", g.Channels())
+	}
+}
+
+func TestWaveforms(t *testing.T) {
+	tests := []struct {
+		waveform  Waveform
+		jsonValue int
+	}{
+		{waveform: WaveformSquare, jsonValue: 0},
+		{waveform: WaveformSawtooth, jsonValue: 1},
+		{waveform: WaveformSine, jsonValue: 2},
+		{waveform: WaveformNoise, jsonValue: 3},
+		{waveform: WaveformTriangle, jsonValue: 4},
+		{waveform: WaveformBreaker, jsonValue: 5},
+		{waveform: WaveformTan, jsonValue: 6},
+		{waveform: WaveformWhistle, jsonValue: 7},
+		{waveform: WaveformPinkNoise, jsonValue: 8},
+		{waveform: WaveformBrownNoise, jsonValue: 9},
+		{waveform: WaveformLFSRNoise, jsonValue: 10},
+	}
+	seen := map[string]Waveform{}
+	for _, tt := range tests {
+		cfg := NewConfig()
+		if err := cfg.InitFromJson([]byte(fmt.Sprintf(`{"waveform": %d}`, tt.jsonValue))); err != nil {
+			t.Fatalf("InitFromJson() failed: %s", err)
+		}
+		if cfg.Waveform != tt.waveform {
+			t.Errorf("waveform %d = %d, want %d", tt.jsonValue, cfg.Waveform, tt.waveform)
+		}
+
+		s := New(cfg).Generate()
+		if len(s) == 0 {
+			t.Fatalf("waveform %d: Generate() returned no samples", tt.waveform)
+		}
+		for i, v := range s {
+			if math.IsNaN(v) || v < -1 || v > 1 {
+				t.Fatalf("waveform %d: sample %d = %f", tt.waveform, i, v)
+			}
+		}
+		key := fmt.Sprint(s[:2000])
+		if other, ok := seen[key]; ok {
+			t.Errorf("waveforms %d and %d sound the same", other, tt.waveform)
+		}
+		seen[key] = tt.waveform
+	}
+}
+
+func TestBitCrush(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Waveform = WaveformSine
+	cfg.BitCrush = 1
+
+	levels := map[float64]bool{}
+	for _, v := range New(cfg).Generate() {
+		levels[v] = true
+	}
+	// A 1-bit sample is negative, zero or positive.
+	if len(levels) > 3 {
+		t.Errorf("got %d different sample values, want at most 3", len(levels))
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Waveform = WaveformSine
+	cfg.Downsample = 1
+
+	s := New(cfg).Generate()
+	for i := 0; i+maxDownsample/2 <= len(s); i += maxDownsample / 2 {
+		for j := i + 1; j < i+maxDownsample/2; j++ {
+			if s[j] != s[i] {
+				t.Fatalf("sample %d = %f, want %f held from sample %d", j, s[j], s[i], i)
+			}
+		}
+	}
+
+	cfg.DownsampleSweep = 1
+	if reflect.DeepEqual(New(cfg).Generate(), s) {
+		t.Errorf("DownsampleSweep has no effect")
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetExplosion(NewRand(1))
+	cfg.Volume = 10
+	peak := func(s []float64) float64 {
+		p := 0.0
+		for _, v := range s {
+			p = math.Max(p, math.Abs(v))
+		}
+		return p
+	}
+	if p := peak(New(cfg).Generate()); p != 1 {
+		t.Fatalf("uncompressed peak = %f, want 1", p)
+	}
+
+	cfg.CompRatio = 1
+	cfg.CompThreshold = 0.8
+	s := New(cfg).Generate()
+	if p := peak(s); p >= 1 {
+		t.Errorf("compressed peak = %f, want < 1", p)
+	}
+
+	cfg.CompMakeup = 0.5
+	if p := peak(New(cfg).Generate()); p <= peak(s) {
+		t.Errorf("makeup gain didn't raise the peak")
+	}
+}
+
+func TestSoftClip(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{0, 0},
+		{0.5, 0.5},
+		{-softClipKnee, -softClipKnee},
+		{0.9, 0.8 + 0.2*math.Tanh(0.5)},
+		{-0.9, -0.8 - 0.2*math.Tanh(0.5)},
+		{100, 1},
+	}
+	for _, tt := range tests {
+		if got := softClip(tt.in); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("softClip(%f) = %f, want %f", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	cfg := NewConfig()
+	cfg.EnvelopeSustain = 3 // 900000 samples, more than 20 seconds
+
+	tests := []struct {
+		name          string
+		g             *Generator
+		wantFrames    int
+		wantTruncated bool
+	}{
+		{name: "Default", g: New(cfg), wantFrames: 10 * DefaultSampleRate, wantTruncated: true},
+		{name: "100ms", g: NewWithOptions(cfg, Options{MaxDuration: 100 * time.Millisecond}), wantFrames: DefaultSampleRate / 10, wantTruncated: true},
+		{name: "100ms at 48 kHz", g: NewWithOptions(cfg, Options{MaxDuration: 100 * time.Millisecond, SampleRate: 48000}), wantFrames: 4800, wantTruncated: true},
+		// Sustain and decay, plus one sample at the end of each stage
+		{name: "Unlimited", g: NewWithOptions(cfg, Options{}), wantFrames: 900000 + 16000 + 2, wantTruncated: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.g.Generate()
+			if len(s) != tt.wantFrames {
+				t.Errorf("got %d frames, want %d", len(s), tt.wantFrames)
+			}
+			if tt.g.Truncated() != tt.wantTruncated {
+				t.Errorf("Truncated() = %t, want %t", tt.g.Truncated(), tt.wantTruncated)
+			}
+			if want := FramesDuration(len(s), tt.g.SampleRate()); tt.g.Duration() != want {
+				t.Errorf("Duration() = %s, want %s", tt.g.Duration(), want)
+			}
+		})
+	}
+}
+
+func TestFramesDuration(t *testing.T) {
+	tests := []struct {
+		frames     int
+		sampleRate int
+		want       time.Duration
+	}{
+		{frames: 0, sampleRate: DefaultSampleRate, want: 0},
+		{frames: DefaultSampleRate, sampleRate: DefaultSampleRate, want: time.Second},
+		{frames: 441, sampleRate: DefaultSampleRate, want: 10 * time.Millisecond},
+		{frames: 480, sampleRate: 48000, want: 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := FramesDuration(tt.frames, tt.sampleRate); got != tt.want {
+			t.Errorf("FramesDuration(%d, %d) = %s, want %s", tt.frames, tt.sampleRate, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	cfg := NewConfig()
+	cfg.PresetLaser(NewRand(5))
+	want := NewWithOptions(cfg, Options{}).Generate()
+	got := NewWithOptions(cfg, Options{SampleRate: DefaultSampleRate, Oversampling: DefaultOversampling}).Generate()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("explicit defaults give a different sound")
+	}
+}
+
+// zeroCrossings returns how often sample changes its sign.
+func zeroCrossings(sample []float64) int {
+	n := 0
+	for i := 1; i < len(sample); i++ {
+		if (sample[i-1] < 0) != (sample[i] < 0) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSampleRateAndOversampling(t *testing.T) {
+	presets := map[string]func(*Config, *rand.Rand){
+		"Laser":   (*Config).PresetLaser,
+		"Jump":    (*Config).PresetJump,
+		"Powerup": (*Config).PresetPowerup,
+		"Blip":    (*Config).PresetBlip,
+	}
+	for _, opts := range []Options{{SampleRate: 22050}, {SampleRate: 48000}, {SampleRate: 96000}, {Oversampling: 2}, {Oversampling: 16}} {
+		for name, preset := range presets {
+			t.Run(fmt.Sprintf("%s at %d Hz, %dx", name, opts.sampleRate(), opts.oversampling()), func(t *testing.T) {
+				cfg := NewConfig()
+				preset(cfg, NewRand(1))
+				want := NewWithOptions(cfg, Options{}).Generate()
+				got := NewWithOptions(cfg, opts).Generate()
+
+				// Same duration and pitch, give or take some rounding
+				scale := float64(opts.sampleRate()) / DefaultSampleRate
+				if ratio := float64(len(got)) / float64(len(want)) / scale; math.Abs(ratio-1) > 0.001 {
+					t.Errorf("got %d samples, want %.0f", len(got), float64(len(want))*scale)
+				}
+				if ratio := float64(zeroCrossings(got)) / float64(zeroCrossings(want)); math.Abs(ratio-1) > 0.03 {
+					t.Errorf("got %d zero crossings, want %d", zeroCrossings(got), zeroCrossings(want))
+				}
+			})
+		}
+	}
+}
+
+func TestLowSampleRate(t *testing.T) {
+	cfg := NewConfig()
+	cfg.FreqStart = 1
+	cfg.FreqSlide = 0.5
+	cfg.VibDepth = 0.5
+	cfg.VibSpeed = 0.5
+	for _, opts := range []Options{{SampleRate: 8000, Oversampling: 1}, {SampleRate: 4000}, {SampleRate: 11025, Oversampling: 2}} {
+		t.Run(fmt.Sprintf("%d Hz, %dx", opts.sampleRate(), opts.oversampling()), func(t *testing.T) {
+			if len(NewWithOptions(cfg, opts).Generate()) == 0 {
+				t.Errorf("Generate() returned no samples")
+			}
+		})
+	}
+}
+
+// TestGenerateMatchesBaseline pins the default output to that of the
+// generator before band limiting, other sample rates, stereo and the new
+// waveforms were added. The hashes were computed with that version, with
+// its global noise source seeded with Config.Seed.
+func TestGenerateMatchesBaseline(t *testing.T) {
+	presets := map[string]func(*Config, *rand.Rand){
+		"Pickup":    (*Config).PresetPickup,
+		"Laser":     (*Config).PresetLaser,
+		"Explosion": (*Config).PresetExplosion,
+		"Powerup":   (*Config).PresetPowerup,
+		"Hit":       (*Config).PresetHit,
+		"Jump":      (*Config).PresetJump,
+		"Blip":      (*Config).PresetBlip,
+	}
+	tests := []struct {
+		preset  string
+		seed    int64
+		samples int
+		sha256  string
+	}{
+		{"Pickup", 1, 6919, "d246da34fd46923fec6a50d799f515cafa5d70d8d943b0254c0c492f16c680c4"},
+		{"Pickup", 2, 4238, "3f91b20580152f3efb640b2eea3ccd9df02445366e4057654a27cfa5f6589cb8"},
+		{"Laser", 1, 10964, "4764d18aab94c993bc19461eaed8b91154c5a6b93c475961fe26490fd1c172ab"},
+		{"Laser", 2, 3933, "bbba52ee77acea5b7552d1b7fd30d53e4424659ffd37a3ec394b6314893c16fe"},
+		{"Explosion", 1, 3157, "4f4c0c72e17d9d9c6aa444a54cf62c3b69ae93e64d054879149f71079a21aa36"},
+		{"Explosion", 2, 4505, "c9a451fd0f57235dc6ded49a4f16dfdf65206a3b615e0e2a344e42559234de78"},
+		{"Powerup", 1, 7198, "292b640fed506f97f4e1b4774c2f4e1643ea889047a6471e37165386a1cec034"},
+		{"Powerup", 2, 7188, "3de4f29e66dbad8c6be3c70e34d5f07e27ea01826edd4a0d7e5731caa211308d"},
+		{"Hit", 1, 3572, "64874c7c4fbf21731e7f97a8441db82526f8a130dc086ffed861b363de8b9623"},
+		{"Hit", 2, 1961, "cfcd88282c7a034f2a0f8e8c84480dbc73f4b415f0212ad37ab69498c1aaee53"},
+		{"Jump", 1, 4236, "6ea11ab25d58c6c6c11bb906b4dabe267632150582db53178a730319dd913088"},
+		{"Jump", 2, 11152, "05aa56eaf8b0c39a10aeb66fc4c47c9847e0f33b2910a7e841b55700438d431f"},
+		{"Blip", 1, 2151, "933a138d1acfa0e8c17cdbb14731b93aec2d5558dbf276d6402828ddbd1bf9d2"},
+		{"Blip", 2, 3189, "55359ea91b03196fa40d56d1582700f05949e13065a02d57a2622b498d4c2f08"},
+	}
+	for _, tt := range tests {
+		cfg := NewConfig()
+		presets[tt.preset](cfg, NewRand(tt.seed))
+		got := NewWithOptions(cfg, Options{}).Generate()
+		h := sha256.New()
+		buf := make([]byte, 8)
+		for _, s := range got {
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(s))
+			h.Write(buf)
+		}
+		if len(got) != tt.samples || fmt.Sprintf("%x", h.Sum(nil)) != tt.sha256 {
+			t.Errorf("%s with seed %d: got %d samples that differ from the baseline", tt.preset, tt.seed, len(got))
+		}
+	}
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// JsfxrURL is the prefix of links to sounds on the jsfxr web site.
+const JsfxrURL = "https://sfxr.me/#"
+
+// jsfxr's default volume. Its serialized strings don't contain the volume.
+const jsfxrDefaultVolume = 0.5
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// jsfxrParams is jsfxr's JSON representation of a sound.
+type jsfxrParams struct {
+	OldParams bool `json:"oldParams"`
+	WaveType  int  `json:"wave_type"`
+
+	EnvAttack  float64 `json:"p_env_attack"`
+	EnvSustain float64 `json:"p_env_sustain"`
+	EnvPunch   float64 `json:"p_env_punch"`
+	EnvDecay   float64 `json:"p_env_decay"`
+
+	BaseFreq  float64 `json:"p_base_freq"`
+	FreqLimit float64 `json:"p_freq_limit"`
+	FreqRamp  float64 `json:"p_freq_ramp"`
+	FreqDramp float64 `json:"p_freq_dramp"`
+
+	VibStrength float64 `json:"p_vib_strength"`
+	VibSpeed    float64 `json:"p_vib_speed"`
+
+	ArpMod   float64 `json:"p_arp_mod"`
+	ArpSpeed float64 `json:"p_arp_speed"`
+
+	Duty     float64 `json:"p_duty"`
+	DutyRamp float64 `json:"p_duty_ramp"`
+
+	RepeatSpeed float64 `json:"p_repeat_speed"`
+
+	PhaOffset float64 `json:"p_pha_offset"`
+	PhaRamp   float64 `json:"p_pha_ramp"`
+
+	LpfFreq      float64 `json:"p_lpf_freq"`
+	LpfRamp      float64 `json:"p_lpf_ramp"`
+	LpfResonance float64 `json:"p_lpf_resonance"`
+	HpfFreq      float64 `json:"p_hpf_freq"`
+	HpfRamp      float64 `json:"p_hpf_ramp"`
+
+	SoundVol   float64 `json:"sound_vol"`
+	SampleRate int     `json:"sample_rate"`
+	SampleSize int     `json:"sample_size"`
+}
+
+func (p *jsfxrParams) values() []*float64 {
+	return []*float64{
+		&p.EnvAttack, &p.EnvSustain, &p.EnvPunch, &p.EnvDecay,
+		&p.BaseFreq, &p.FreqLimit, &p.FreqRamp, &p.FreqDramp,
+		&p.VibStrength, &p.VibSpeed,
+		&p.ArpMod, &p.ArpSpeed,
+		&p.Duty, &p.DutyRamp,
+		&p.RepeatSpeed,
+		&p.PhaOffset, &p.PhaRamp,
+		&p.LpfFreq, &p.LpfRamp, &p.LpfResonance,
+		&p.HpfFreq, &p.HpfRamp,
+	}
+}
+
+// jsfxrValues returns the config values in the order jsfxr serializes them.
+func (g *Config) jsfxrValues() []*float64 {
+	return []*float64{
+		&g.EnvelopeAttack, &g.EnvelopeSustain, &g.EnvelopeSustainPunch, &g.EnvelopeDecay,
+		&g.FreqStart, &g.FreqMinCutoff, &g.FreqSlide, &g.FreqDeltaSlide,
+		&g.VibDepth, &g.VibSpeed,
+		&g.ArpFreqMult, &g.ArpChangeSpeed,
+		&g.DutyCycle, &g.DutyCycleSweep,
+		&g.RepeatRate,
+		&g.PhaserOffset, &g.PhaserSweep,
+		&g.LPCutoffFreq, &g.LPCutoffSweep, &g.LPResonance,
+		&g.HPCutoffFreq, &g.HPCutoffSweep,
+	}
+}
+
+func jsfxrWaveform(waveType int) (Waveform, error) {
+	if waveType < 0 || waveType > int(WaveformNoise) {
+		return 0, fmt.Errorf("unsupported waveform %d", waveType)
+	}
+	return Waveform(waveType), nil
+}
+
+// jsfxrWaveType returns the waveform jsfxr should use for wf. jsfxr only
+// knows sfxr's waveforms, so the others are replaced by the closest one.
+func jsfxrWaveType(wf Waveform) int {
+	switch wf {
+	case WaveformTriangle, WaveformBreaker, WaveformTan, WaveformWhistle:
+		return int(WaveformSine)
+	case WaveformPinkNoise, WaveformBrownNoise, WaveformLFSRNoise:
+		return int(WaveformNoise)
+	}
+	return int(wf)
+}
+
+// InitFromJsfxrJson reads a sound in jsfxr's JSON format.
+func (g *Config) InitFromJsfxrJson(j []byte) error {
+	c := Config{}
+	c.Reset()
+	c.Volume = jsfxrDefaultVolume
+
+	// Start from the defaults, so that missing values don't end up as 0.
+	p := c.toJsfxrParams()
+	if err := json.Unmarshal(j, p); err != nil {
+		return err
+	}
+	wf, err := jsfxrWaveform(p.WaveType)
+	if err != nil {
+		return err
+	}
+	c.Waveform = wf
+	c.Volume = p.SoundVol
+	vals := c.jsfxrValues()
+	for i, v := range p.values() {
+		*vals[i] = *v
+	}
+	*g = c
+	return nil
+}
+
+func (g *Config) toJsfxrParams() *jsfxrParams {
+	p := &jsfxrParams{
+		OldParams:  true,
+		WaveType:   jsfxrWaveType(g.Waveform),
+		SoundVol:   g.Volume,
+		SampleRate: 44100,
+		SampleSize: 16,
+	}
+	vals := p.values()
+	for i, v := range g.jsfxrValues() {
+		*vals[i] = *v
+	}
+	return p
+}
+
+// ToJsfxrJson returns the configuration in jsfxr's JSON format.
+func (g *Config) ToJsfxrJson() []byte {
+	content, _ := json.MarshalIndent(g.toJsfxrParams(), "", "    ")
+	return content
+}
+
+// InitFromJsfxrString reads a sound serialized by jsfxr. s is either the
+// base58 string, or a link containing it after a '#'.
+func (g *Config) InitFromJsfxrString(s string) error {
+	s = strings.TrimSpace(s)
+	if idx := strings.LastIndex(s, "#"); idx >= 0 {
+		s = s[idx+1:]
+	}
+	data, err := base58Decode(s)
+	if err != nil {
+		return err
+	}
+	if len(data) != 1+22*4 {
+		return fmt.Errorf("invalid jsfxr string: got %d bytes, want %d", len(data), 1+22*4)
+	}
+	wf, err := jsfxrWaveform(int(data[0]))
+	if err != nil {
+		return err
+	}
+
+	c := Config{}
+	c.Reset()
+	c.Waveform = wf
+	c.Volume = jsfxrDefaultVolume
+	for i, v := range c.jsfxrValues() {
+		bits := binary.LittleEndian.Uint32(data[1+4*i:])
+		*v = float64(math.Float32frombits(bits))
+	}
+	*g = c
+	return nil
+}
+
+// ToJsfxrString returns the configuration serialized the way jsfxr does it
+// in its links. The volume is not part of it.
+func (g *Config) ToJsfxrString() string {
+	buf := bytes.NewBuffer([]byte{byte(jsfxrWaveType(g.Waveform))})
+	for _, v := range g.jsfxrValues() {
+		binary.Write(buf, binary.LittleEndian, float32(*v))
+	}
+	return base58Encode(buf.Bytes())
+}
+
+// InitFromJsfxr reads a sound from anything jsfxr produces: its JSON, a
+// serialized string, or a link.
+func (g *Config) InitFromJsfxr(s string) error {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return g.InitFromJsfxrJson([]byte(s))
+	}
+	return g.InitFromJsfxrString(s)
+}
+
+func base58Encode(data []byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		sb.WriteByte(base58Alphabet[0])
+	}
+
+	var digits []byte
+	n := new(big.Int).SetBytes(data)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		digits = append(digits, base58Alphabet[mod.Int64()])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		sb.WriteByte(digits[i])
+	}
+	return sb.String()
+}
+
+func base58Decode(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range s[zeros:] {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
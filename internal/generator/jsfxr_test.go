@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{data: []byte("hello world"), want: "StV1DL6CwTryKyV"},
+		{data: []byte{0, 0, 1}, want: "112"},
+		{data: []byte{}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := base58Encode(tt.data); got != tt.want {
+			t.Errorf("base58Encode(%v) = %q, want %q", tt.data, got, tt.want)
+		}
+		got, err := base58Decode(tt.want)
+		if err != nil || !reflect.DeepEqual(got, tt.data) {
+			t.Errorf("base58Decode(%q) = %v, %v, want %v", tt.want, got, err, tt.data)
+		}
+	}
+	if _, err := base58Decode("0OIl"); err == nil {
+		t.Errorf("base58Decode() accepted invalid characters")
+	}
+}
+
+func TestJsfxrRoundTrip(t *testing.T) {
+	orig := NewConfig()
+	orig.PresetLaser(NewRand(5))
+	orig.Seed = 0
+	orig.VibDelay = 0 // not supported by jsfxr
+
+	t.Run("String", func(t *testing.T) {
+		got := NewConfig()
+		if err := got.InitFromJsfxr(JsfxrURL + orig.ToJsfxrString()); err != nil {
+			t.Fatalf("InitFromJsfxr() failed: %s", err)
+		}
+		want := float32Config(orig)
+		want.Volume = jsfxrDefaultVolume
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+	t.Run("JSON", func(t *testing.T) {
+		got := NewConfig()
+		if err := got.InitFromJsfxr(string(orig.ToJsfxrJson())); err != nil {
+			t.Fatalf("InitFromJsfxr() failed: %s", err)
+		}
+		if !reflect.DeepEqual(got, orig) {
+			t.Errorf("got %+v, want %+v", got, orig)
+		}
+	})
+}
+
+func TestJsfxrJsonDefaults(t *testing.T) {
+	c := NewConfig()
+	if err := c.InitFromJsfxrJson([]byte(`{"wave_type": 3, "p_base_freq": 0.5}`)); err != nil {
+		t.Fatalf("InitFromJsfxrJson() failed: %s", err)
+	}
+	want := NewConfig()
+	want.Waveform = WaveformNoise
+	want.FreqStart = 0.5
+	want.Volume = jsfxrDefaultVolume
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	if err := c.InitFromJsfxrJson([]byte(`{"wave_type": 9}`)); err == nil {
+		t.Errorf("InitFromJsfxrJson() accepted an unknown waveform")
+	}
+}
+
+func TestJsfxrWaveType(t *testing.T) {
+	tests := []struct {
+		wf   Waveform
+		want Waveform
+	}{
+		{WaveformSawtooth, WaveformSawtooth},
+		{WaveformNoise, WaveformNoise},
+		{WaveformTriangle, WaveformSine},
+		{WaveformWhistle, WaveformSine},
+		{WaveformPinkNoise, WaveformNoise},
+		{WaveformLFSRNoise, WaveformNoise},
+	}
+	for _, tc := range tests {
+		c := NewConfig()
+		c.Waveform = tc.wf
+		got := NewConfig()
+		if err := got.InitFromJsfxr(c.ToJsfxrString()); err != nil {
+			t.Fatalf("%d: InitFromJsfxr() failed: %s", tc.wf, err)
+		}
+		if got.Waveform != tc.want {
+			t.Errorf("%d: got waveform %d, want %d", tc.wf, got.Waveform, tc.want)
+		}
+	}
+}
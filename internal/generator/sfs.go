@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// SfsVersion is the latest version of DrPetter's sfxr settings file format.
+const SfsVersion = 102
+
+// sfxr's default volume, used for files older than version 102.
+const sfsDefaultVolume = 0.5
+
+// InitFromSfs reads a settings file saved by DrPetter's sfxr. Versions
+// 100, 101 and 102 are supported.
+func (g *Config) InitFromSfs(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var version, waveform int32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != 100 && version != 101 && version != 102 {
+		return fmt.Errorf("unsupported sfs version %d", version)
+	}
+
+	c := Config{}
+	c.Reset()
+	c.Volume = sfsDefaultVolume
+	var filterOn byte
+	fields := []interface{}{&waveform}
+	if version == 102 {
+		fields = append(fields, f32(&c.Volume))
+	}
+	fields = append(fields, f32(&c.FreqStart), f32(&c.FreqMinCutoff), f32(&c.FreqSlide))
+	if version >= 101 {
+		fields = append(fields, f32(&c.FreqDeltaSlide))
+	}
+	fields = append(fields,
+		f32(&c.DutyCycle), f32(&c.DutyCycleSweep),
+		f32(&c.VibDepth), f32(&c.VibSpeed), f32(&c.VibDelay),
+		f32(&c.EnvelopeAttack), f32(&c.EnvelopeSustain), f32(&c.EnvelopeDecay), f32(&c.EnvelopeSustainPunch),
+		&filterOn,
+		f32(&c.LPResonance), f32(&c.LPCutoffFreq), f32(&c.LPCutoffSweep),
+		f32(&c.HPCutoffFreq), f32(&c.HPCutoffSweep),
+		f32(&c.PhaserOffset), f32(&c.PhaserSweep),
+		f32(&c.RepeatRate))
+	if version >= 101 {
+		fields = append(fields, f32(&c.ArpChangeSpeed), f32(&c.ArpFreqMult))
+	}
+
+	for _, f := range fields {
+		if err := readSfsField(r, f); err != nil {
+			return err
+		}
+	}
+	if waveform < 0 || waveform > int32(WaveformNoise) {
+		return fmt.Errorf("unsupported waveform %d", waveform)
+	}
+	c.Waveform = Waveform(waveform)
+
+	*g = c
+	return nil
+}
+
+// ToSfs returns the configuration in DrPetter's sfxr settings file format.
+// Older versions than SfsVersion lose the parameters they don't know about.
+func (g *Config) ToSfs(version int) ([]byte, error) {
+	if version != 100 && version != 101 && version != 102 {
+		return nil, fmt.Errorf("unsupported sfs version %d", version)
+	}
+	if g.Waveform > WaveformNoise {
+		return nil, fmt.Errorf("waveform %d can't be stored in sfs files", g.Waveform)
+	}
+
+	fields := []interface{}{int32(version), int32(g.Waveform)}
+	if version == 102 {
+		fields = append(fields, float32(g.Volume))
+	}
+	fields = append(fields, float32(g.FreqStart), float32(g.FreqMinCutoff), float32(g.FreqSlide))
+	if version >= 101 {
+		fields = append(fields, float32(g.FreqDeltaSlide))
+	}
+	fields = append(fields,
+		float32(g.DutyCycle), float32(g.DutyCycleSweep),
+		float32(g.VibDepth), float32(g.VibSpeed), float32(g.VibDelay),
+		float32(g.EnvelopeAttack), float32(g.EnvelopeSustain), float32(g.EnvelopeDecay), float32(g.EnvelopeSustainPunch),
+		byte(1), // filter_on, unused by sfxr
+		float32(g.LPResonance), float32(g.LPCutoffFreq), float32(g.LPCutoffSweep),
+		float32(g.HPCutoffFreq), float32(g.HPCutoffSweep),
+		float32(g.PhaserOffset), float32(g.PhaserSweep),
+		float32(g.RepeatRate))
+	if version >= 101 {
+		fields = append(fields, float32(g.ArpChangeSpeed), float32(g.ArpFreqMult))
+	}
+
+	var buf bytes.Buffer
+	for _, f := range fields {
+		binary.Write(&buf, binary.LittleEndian, f)
+	}
+	return buf.Bytes(), nil
+}
+
+// sfsFloat is a float64 config value that is stored as float32 in sfs files.
+type sfsFloat struct {
+	val *float64
+}
+
+func f32(val *float64) sfsFloat {
+	return sfsFloat{val}
+}
+
+func readSfsField(r io.Reader, field interface{}) error {
+	var err error
+	if f, ok := field.(sfsFloat); ok {
+		var v float32
+		err = binary.Read(r, binary.LittleEndian, &v)
+		*f.val = float64(v)
+	} else {
+		err = binary.Read(r, binary.LittleEndian, field)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("truncated sfs file")
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func float32Config(c *Config) *Config {
+	// sfs files store float32s, so values are rounded on a round trip.
+	r := *c
+	for _, f := range []*float64{
+		&r.Volume, &r.EnvelopeAttack, &r.EnvelopeSustain, &r.EnvelopeSustainPunch, &r.EnvelopeDecay,
+		&r.FreqStart, &r.FreqMinCutoff, &r.FreqSlide, &r.FreqDeltaSlide,
+		&r.VibDepth, &r.VibSpeed, &r.VibDelay, &r.ArpFreqMult, &r.ArpChangeSpeed,
+		&r.DutyCycle, &r.DutyCycleSweep, &r.RepeatRate, &r.PhaserOffset, &r.PhaserSweep,
+		&r.LPCutoffFreq, &r.LPCutoffSweep, &r.LPResonance, &r.HPCutoffFreq, &r.HPCutoffSweep,
+	} {
+		*f = float64(float32(*f))
+	}
+	return &r
+}
+
+func TestSfsRoundTrip(t *testing.T) {
+	orig := NewConfig()
+	orig.Randomize(NewRand(99))
+	orig.Waveform = WaveformSine
+	orig.Volume = 0.7
+	orig.Seed = 0 // not stored in sfs files
+	orig.BitCrush, orig.BitCrushSweep = 0, 0
+	orig.Downsample, orig.DownsampleSweep = 0, 0
+
+	tests := []struct {
+		version int
+		want    func(c *Config)
+	}{
+		{version: 102, want: func(c *Config) {}},
+		{version: 101, want: func(c *Config) { c.Volume = 0.5 }},
+		{version: 100, want: func(c *Config) {
+			c.Volume = 0.5
+			c.FreqDeltaSlide = 0
+			c.ArpChangeSpeed = 0
+			c.ArpFreqMult = 0
+		}},
+	}
+	for _, tt := range tests {
+		data, err := orig.ToSfs(tt.version)
+		if err != nil {
+			t.Fatalf("ToSfs(%d) failed: %s", tt.version, err)
+		}
+		got := NewConfig()
+		if err := got.InitFromSfs(data); err != nil {
+			t.Fatalf("InitFromSfs() failed for version %d: %s", tt.version, err)
+		}
+		want := float32Config(orig)
+		tt.want(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: got %+v, want %+v", tt.version, got, want)
+		}
+	}
+}
+
+func TestSfsLayout(t *testing.T) {
+	c := NewConfig()
+	c.Waveform = WaveformNoise
+	c.ArpFreqMult = -0.5
+	data, _ := c.ToSfs(SfsVersion)
+
+	// version, waveform, 24 floats, filter_on flag
+	if len(data) != 4+4+24*4+1 {
+		t.Fatalf("len(data) = %d", len(data))
+	}
+	if !reflect.DeepEqual(data[:8], []byte{102, 0, 0, 0, 3, 0, 0, 0}) {
+		t.Errorf("header = %v", data[:8])
+	}
+	last := math.Float32frombits(uint32(data[len(data)-4]) | uint32(data[len(data)-3])<<8 | uint32(data[len(data)-2])<<16 | uint32(data[len(data)-1])<<24)
+	if last != -0.5 {
+		t.Errorf("arp_mod = %v, want -0.5", last)
+	}
+}
+
+func TestSfsErrors(t *testing.T) {
+	c := NewConfig()
+	if err := c.InitFromSfs([]byte{103, 0, 0, 0}); err == nil {
+		t.Errorf("InitFromSfs() accepted version 103")
+	}
+	data, _ := c.ToSfs(SfsVersion)
+	if err := c.InitFromSfs(data[:20]); err == nil {
+		t.Errorf("InitFromSfs() accepted a truncated file")
+	}
+	if _, err := c.ToSfs(99); err == nil {
+		t.Errorf("ToSfs() accepted version 99")
+	}
+}
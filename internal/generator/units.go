@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"math"
+	"time"
+)
+
+// The conversions below translate Config values to physical units and back.
+// They follow the formulas in init and initForRepeat. Since rates and
+// lengths are scaled with the sample rate, the units don't depend on it.
+// Values that are out of range are clamped, and effects of other settings,
+// like the rounding of the period, the stereo detune or the delta slide, are
+// ignored.
+
+// freqScale is the frequency in Hz for FreqStart² + 0.001 = 1. sfxr's
+// period is 100/(FreqStart²+0.001) supersamples.
+const freqScale = DefaultSampleRate * DefaultOversampling / 100.0
+
+// FreqToHz returns the frequency of a FreqStart or FreqMinCutoff value.
+func FreqToHz(f float64) float64 {
+	return freqScale * (f*f + 0.001)
+}
+
+// HzToFreq returns the FreqStart or FreqMinCutoff value for a frequency. It
+// is clamped to the range from about 3.5 Hz to 3.5 kHz that the generator
+// supports.
+func HzToFreq(hz float64) float64 {
+	return clamp(math.Sqrt(math.Max(0, hz/freqScale-0.001)), 0, 1)
+}
+
+// SlideToOctaves returns how many octaves per second a FreqSlide value
+// raises the pitch by. Negative values lower it.
+func SlideToOctaves(s float64) float64 {
+	return -math.Log2(1-s*s*s*0.01) * DefaultSampleRate
+}
+
+// OctavesToSlide returns the FreqSlide value that changes the pitch by the
+// given number of octaves per second.
+func OctavesToSlide(octaves float64) float64 {
+	return clamp(math.Cbrt((1-math.Exp2(-octaves/DefaultSampleRate))/0.01), -1, 1)
+}
+
+// EnvelopeToDuration returns the length of the envelope stage of an
+// EnvelopeAttack, EnvelopeSustain or EnvelopeDecay value. It also converts
+// VibDelay.
+func EnvelopeToDuration(e float64) time.Duration {
+	return samplesDuration(e * e * 100000)
+}
+
+// DurationToEnvelope returns the EnvelopeAttack, EnvelopeSustain,
+// EnvelopeDecay or VibDelay value for a duration of at most 2.27 seconds.
+func DurationToEnvelope(d time.Duration) float64 {
+	return clamp(math.Sqrt(math.Max(0, durationSamples(d)/100000)), 0, 1)
+}
+
+// VibSpeedToHz returns the vibrato rate of a VibSpeed value.
+func VibSpeedToHz(v float64) float64 {
+	return v * v * 0.01 * DefaultSampleRate / (2 * math.Pi)
+}
+
+// HzToVibSpeed returns the VibSpeed value for a vibrato rate of at most
+// about 70 Hz.
+func HzToVibSpeed(hz float64) float64 {
+	return clamp(math.Sqrt(math.Max(0, hz*2*math.Pi/DefaultSampleRate/0.01)), 0, 1)
+}
+
+// ArpMultToSemitones returns the interval the arpeggio jumps by for an
+// ArpFreqMult value. Positive values jump up.
+func ArpMultToSemitones(a float64) float64 {
+	mod := 1 - a*a*0.9
+	if a < 0 {
+		mod = 1 + a*a*10
+	}
+	return -12 * math.Log2(mod)
+}
+
+// SemitonesToArpMult returns the ArpFreqMult value for an interval from
+// about 41 semitones down to 39 semitones up.
+func SemitonesToArpMult(semitones float64) float64 {
+	mod := math.Exp2(-semitones / 12)
+	if mod <= 1 {
+		return math.Sqrt(math.Min(1, (1-mod)/0.9))
+	}
+	return -math.Sqrt(math.Min(1, (mod-1)/10))
+}
+
+// ArpSpeedToDuration returns after how long the arpeggio jumps for an
+// ArpChangeSpeed value. It returns 0 for 1, which never jumps.
+func ArpSpeedToDuration(a float64) time.Duration {
+	if a == 1 {
+		return 0
+	}
+	return rateDuration(a)
+}
+
+// DurationToArpSpeed returns the ArpChangeSpeed value for a duration
+// between 0.73 and 454 ms. A duration of 0 never jumps.
+func DurationToArpSpeed(d time.Duration) float64 {
+	if d <= 0 {
+		return 1
+	}
+	return durationRate(d)
+}
+
+// RepeatRateToDuration returns after how long the sound repeats for a
+// RepeatRate value. It returns 0 for 0, which doesn't repeat.
+func RepeatRateToDuration(r float64) time.Duration {
+	if r == 0 {
+		return 0
+	}
+	return rateDuration(r)
+}
+
+// DurationToRepeatRate returns the RepeatRate value for a duration between
+// 0.73 and 454 ms. A duration of 0 doesn't repeat.
+func DurationToRepeatRate(d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return durationRate(d)
+}
+
+// rateDuration returns the period of an ArpChangeSpeed or RepeatRate value.
+func rateDuration(r float64) time.Duration {
+	return samplesDuration((1-r)*(1-r)*20000 + 32)
+}
+
+// durationRate returns the ArpChangeSpeed or RepeatRate value for a period.
+// 0 and 1 are avoided, because they turn the effect off.
+func durationRate(d time.Duration) float64 {
+	r := 1 - math.Sqrt(math.Max(0, durationSamples(d)-32)/20000)
+	return clamp(r, math.Nextafter(0, 1), math.Nextafter(1, 0))
+}
+
+func samplesDuration(samples float64) time.Duration {
+	return time.Duration(samples / DefaultSampleRate * float64(time.Second))
+}
+
+func durationSamples(d time.Duration) float64 {
+	return d.Seconds() * DefaultSampleRate
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package generator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUnits(t *testing.T) {
+	ms := func(f func(time.Duration) float64) func(float64) float64 {
+		return func(v float64) float64 { return f(time.Duration(v * float64(time.Millisecond))) }
+	}
+	toMs := func(f func(float64) time.Duration) func(float64) float64 {
+		return func(v float64) float64 { return float64(f(v)) / float64(time.Millisecond) }
+	}
+	tests := []struct {
+		name  string
+		to    func(float64) float64
+		from  func(float64) float64
+		value float64
+		want  float64
+	}{
+		{name: "Lowest frequency", to: FreqToHz, from: HzToFreq, value: 0, want: 3.528},
+		{name: "Highest frequency", to: FreqToHz, from: HzToFreq, value: 1, want: 3531.528},
+		{name: "A5", to: FreqToHz, from: HzToFreq, value: math.Sqrt(880.0/3528 - 0.001), want: 880},
+		{name: "No slide", to: SlideToOctaves, from: OctavesToSlide, value: 0, want: 0},
+		{name: "Slide up", to: SlideToOctaves, from: OctavesToSlide, value: 0.1, want: -math.Log2(1-0.00001) * 44100},
+		{name: "Slide down", to: SlideToOctaves, from: OctavesToSlide, value: -0.1, want: -math.Log2(1+0.00001) * 44100},
+		{name: "Longest envelope", to: toMs(EnvelopeToDuration), from: ms(DurationToEnvelope), value: 1, want: 100000 / 44.1},
+		{name: "Short envelope", to: toMs(EnvelopeToDuration), from: ms(DurationToEnvelope), value: 0.21, want: 0.21 * 0.21 * 100000 / 44.1},
+		{name: "Fastest vibrato", to: VibSpeedToHz, from: HzToVibSpeed, value: 1, want: 441 / (2 * math.Pi)},
+		{name: "Octave up", to: ArpMultToSemitones, from: SemitonesToArpMult, value: math.Sqrt(0.5 / 0.9), want: 12},
+		{name: "Octave down", to: ArpMultToSemitones, from: SemitonesToArpMult, value: -math.Sqrt(0.1), want: -12},
+		{name: "No arpeggio", to: ArpMultToSemitones, from: SemitonesToArpMult, value: 0, want: 0},
+		{name: "Arpeggio off", to: toMs(ArpSpeedToDuration), from: ms(DurationToArpSpeed), value: 1, want: 0},
+		{name: "Slowest arpeggio", to: toMs(ArpSpeedToDuration), from: ms(DurationToArpSpeed), value: 0.5, want: 5032 / 44.1},
+		{name: "Repeat off", to: toMs(RepeatRateToDuration), from: ms(DurationToRepeatRate), value: 0, want: 0},
+		{name: "Repeat", to: toMs(RepeatRateToDuration), from: ms(DurationToRepeatRate), value: 0.75, want: 1282 / 44.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.to(tt.value); math.Abs(got-tt.want) > 1e-3*math.Max(1, math.Abs(tt.want)) {
+				t.Errorf("converting %f gave %f, want %f", tt.value, got, tt.want)
+			}
+			if got := tt.from(tt.want); math.Abs(got-tt.value) > 1e-3 {
+				t.Errorf("converting %f back gave %f, want %f", tt.want, got, tt.value)
+			}
+		})
+	}
+}
+
+func TestUnitsClamp(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "Frequency too low", got: HzToFreq(1), want: 0},
+		{name: "Frequency too high", got: HzToFreq(10000), want: 1},
+		{name: "Slide too fast", got: OctavesToSlide(-10000), want: -1},
+		{name: "Envelope too long", got: DurationToEnvelope(time.Minute), want: 1},
+		{name: "Negative envelope", got: DurationToEnvelope(-time.Second), want: 0},
+		{name: "Vibrato too fast", got: HzToVibSpeed(1000), want: 1},
+		{name: "Interval too large", got: SemitonesToArpMult(48), want: 1},
+		{name: "Interval too small", got: SemitonesToArpMult(-48), want: -1},
+		{name: "Repeat too fast", got: DurationToRepeatRate(time.Microsecond), want: math.Nextafter(1, 0)},
+		{name: "Repeat too slow", got: DurationToRepeatRate(time.Minute), want: math.Nextafter(0, 1)},
+		{name: "Arpeggio too fast", got: DurationToArpSpeed(time.Microsecond), want: math.Nextafter(1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %g, want %g", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitsGenerate(t *testing.T) {
+	c := NewConfig()
+	c.Reset()
+	c.Waveform = WaveformSine
+	c.FreqStart = HzToFreq(880)
+	c.EnvelopeAttack = 0
+	c.EnvelopeSustain = DurationToEnvelope(120 * time.Millisecond)
+	c.EnvelopeDecay = 0
+	g := New(c)
+	s := g.Generate()
+	if got := FramesDuration(len(s), g.SampleRate()); got.Round(time.Millisecond) != 120*time.Millisecond {
+		t.Errorf("sound is %s long, want 120ms", got)
+	}
+	// The period is rounded to whole supersamples.
+	if got := float64(zeroCrossings(s)) / 2 / 0.12; math.Abs(got/880-1) > 0.01 {
+		t.Errorf("sound has %.1f Hz, want 880 Hz", got)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package layered mixes several gosfxr sounds into one, e.g. a laser zap on
+// top of an explosion. Layered sounds are stored as JSON, in files ending in
+// Extension.
+package layered
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/asig/gosfxr/internal/generator"
+)
+
+// Extension of layered sound files
+const Extension = ".sfxl"
+
+// Layer is one of the sounds that make up a layered sound.
+type Layer struct {
+	Config *generator.Config `json:"config"`
+	// Start of the layer in seconds after the start of the sound
+	Offset float64 `json:"offset"`
+	// Gain the layer is mixed with
+	Gain float64 `json:"gain"`
+	// Pitch offset in semitones
+	Pitch float64 `json:"pitch"`
+}
+
+// NewLayer returns a layer that plays cfg as it is.
+func NewLayer(cfg *generator.Config) *Layer {
+	return &Layer{Config: cfg, Gain: 1}
+}
+
+// UnmarshalJSON decodes a layer. Missing values keep their defaults, both
+// in the layer and in its configuration.
+func (l *Layer) UnmarshalJSON(j []byte) error {
+	type layer Layer
+	aux := layer(*NewLayer(generator.NewConfig()))
+	if err := json.Unmarshal(j, &aux); err != nil {
+		return err
+	}
+	*l = Layer(aux)
+	return nil
+}
+
+// Sound is a layered sound.
+type Sound struct {
+	Layers []*Layer `json:"layers"`
+}
+
+// New returns a sound with cfg as its only layer.
+func New(cfg *generator.Config) *Sound {
+	return &Sound{Layers: []*Layer{NewLayer(cfg)}}
+}
+
+func (s *Sound) InitFromJson(j []byte) error {
+	var res Sound
+	if err := json.Unmarshal(j, &res); err != nil {
+		return err
+	}
+	if len(res.Layers) == 0 {
+		return fmt.Errorf("sound has no layers")
+	}
+	for i, l := range res.Layers {
+		if l == nil || l.Config == nil {
+			return fmt.Errorf("layer %d has no configuration", i+1)
+		}
+	}
+	*s = res
+	return nil
+}
+
+func (s *Sound) ToJson() []byte {
+	content, _ := json.MarshalIndent(s, "", "    ")
+	return content
+}
+
+// Channels returns 2 if any of the layers is stereo, 1 otherwise.
+func (s *Sound) Channels() int {
+	for _, l := range s.Layers {
+		if l.Config.Channels() == 2 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Render generates every layer and mixes them. Mono layers are played on
+// both channels of stereo sounds. The samples are interleaved, and truncated
+// reports whether any layer was cut off at opts.MaxDuration.
+func (s *Sound) Render(opts generator.Options) (samples []float64, channels int, truncated bool) {
+	channels = s.Channels()
+	for _, l := range s.Layers {
+		cfg := *l.Config
+		if l.Pitch != 0 {
+			cfg.Transpose(l.Pitch)
+		}
+		g := generator.NewWithOptions(&cfg, opts)
+		layer := g.Generate()
+		truncated = truncated || g.Truncated()
+
+		layerChannels := g.Channels()
+		start := int(math.Max(0, l.Offset) * float64(g.SampleRate()))
+		frames := len(layer) / layerChannels
+		if n := (start + frames) * channels; n > len(samples) {
+			samples = append(samples, make([]float64, n-len(samples))...)
+		}
+		for i := 0; i < frames; i++ {
+			for c := 0; c < channels; c++ {
+				samples[(start+i)*channels+c] += layer[i*layerChannels+c%layerChannels] * l.Gain
+			}
+		}
+	}
+	for i, v := range samples {
+		samples[i] = math.Max(-1, math.Min(1, v))
+	}
+	return samples, channels, truncated
+}
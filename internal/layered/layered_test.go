@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package layered
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/generator"
+)
+
+func preset(f func(*generator.Config, *rand.Rand), seed int64) *generator.Config {
+	cfg := generator.NewConfig()
+	f(cfg, generator.NewRand(seed))
+	return cfg
+}
+
+// mix adds src, scaled by gain, to dst from the given sample on.
+func mix(dst, src []float64, offset int, gain float64) []float64 {
+	if n := offset + len(src); n > len(dst) {
+		dst = append(dst, make([]float64, n-len(dst))...)
+	}
+	for i, v := range src {
+		dst[offset+i] += v * gain
+	}
+	return dst
+}
+
+func TestRenderSingleLayer(t *testing.T) {
+	cfg := preset((*generator.Config).PresetLaser, 1)
+	want := generator.New(cfg).Generate()
+	got, channels, truncated := New(cfg).Render(generator.Options{MaxDuration: generator.DefaultMaxDuration})
+	if !reflect.DeepEqual(got, want) || channels != 1 || truncated {
+		t.Errorf("Render() differs from Generate(): %d samples, %d channels, truncated %t", len(got), channels, truncated)
+	}
+}
+
+func TestRender(t *testing.T) {
+	explosion := preset((*generator.Config).PresetExplosion, 2)
+	laser := preset((*generator.Config).PresetLaser, 3)
+	transposed := *laser
+	transposed.Transpose(5)
+
+	e := generator.New(explosion).Generate()
+	l := generator.New(laser).Generate()
+
+	tests := []struct {
+		name   string
+		layers []*Layer
+		want   []float64
+	}{
+		{
+			name:   "Offset and gain",
+			layers: []*Layer{NewLayer(explosion), {Config: laser, Offset: 0.1, Gain: 0.5}},
+			want:   mix(mix(nil, e, 0, 1), l, generator.DefaultSampleRate/10, 0.5),
+		},
+		{
+			name:   "Pitch",
+			layers: []*Layer{{Config: laser, Gain: 1, Pitch: 5}},
+			want:   generator.New(&transposed).Generate(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, channels, _ := (&Sound{Layers: tt.layers}).Render(generator.Options{})
+			if channels != 1 {
+				t.Errorf("got %d channels, want 1", channels)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %d samples that differ from the %d expected ones", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRenderStereo(t *testing.T) {
+	mono := preset((*generator.Config).PresetExplosion, 2)
+	right := preset((*generator.Config).PresetLaser, 3)
+	right.Pan = 1
+
+	m := generator.New(mono).Generate()
+	r := generator.New(right).Generate()
+
+	got, channels, _ := (&Sound{Layers: []*Layer{NewLayer(mono), NewLayer(right)}}).Render(generator.Options{})
+	if channels != 2 {
+		t.Fatalf("got %d channels, want 2", channels)
+	}
+	// The mono layer plays on both channels, the panned one only on the right.
+	for i := 0; i < len(got)/2; i++ {
+		var wantLeft, wantRight float64
+		if i < len(m) {
+			wantLeft, wantRight = m[i], m[i]
+		}
+		if i < len(r)/2 {
+			wantLeft += r[2*i]
+			wantRight += r[2*i+1]
+		}
+		if got[2*i] != wantLeft || got[2*i+1] != wantRight {
+			t.Fatalf("frame %d = (%f, %f), want (%f, %f)", i, got[2*i], got[2*i+1], wantLeft, wantRight)
+		}
+	}
+}
+
+func TestRenderClips(t *testing.T) {
+	cfg := generator.NewConfig()
+	cfg.Volume = 10
+	got, _, _ := (&Sound{Layers: []*Layer{{Config: cfg, Gain: 2}}}).Render(generator.Options{})
+	for i, v := range got {
+		if math.Abs(v) > 1 {
+			t.Fatalf("sample %d = %f, want it clipped", i, v)
+		}
+	}
+}
+
+func TestJson(t *testing.T) {
+	s := New(preset((*generator.Config).PresetBlip, 4))
+	s.Layers = append(s.Layers, &Layer{Config: preset((*generator.Config).PresetHit, 5), Offset: 0.25, Gain: 0.8, Pitch: -3})
+
+	got := &Sound{}
+	if err := got.InitFromJson(s.ToJson()); err != nil {
+		t.Fatalf("InitFromJson() failed: %s", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("round trip gave %+v, want %+v", got, s)
+	}
+}
+
+func TestJsonDefaults(t *testing.T) {
+	got := &Sound{}
+	if err := got.InitFromJson([]byte(`{"layers": [{"config": {"base_freq": 0.5}}]}`)); err != nil {
+		t.Fatalf("InitFromJson() failed: %s", err)
+	}
+	cfg := generator.NewConfig()
+	cfg.FreqStart = 0.5
+	if want := New(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got.Layers[0], want.Layers[0])
+	}
+
+	for _, j := range []string{`{"layers": []}`, `{}`, `[`} {
+		if err := got.InitFromJson([]byte(j)); err == nil {
+			t.Errorf("InitFromJson(%s) succeeded", j)
+		}
+	}
+}
+
+func TestJsonNullLayers(t *testing.T) {
+	for _, j := range []string{`{"layers": [null]}`, `{"layers": [{"config": null}]}`, `{"layers": [{"gain": 0.5}, null]}`} {
+		var got Sound
+		if err := got.InitFromJson([]byte(j)); err == nil {
+			t.Errorf("InitFromJson(%s) succeeded", j)
+		}
+	}
+}
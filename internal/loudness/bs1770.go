@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package loudness
+
+import "math"
+
+// biquad is a second order IIR filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two stages of BS.1770's K-weighting filter, a high
+// shelf modelling the head followed by a high pass. BS.1770 only gives the
+// coefficients for 48 kHz; these are derived for any sample rate from the
+// analog prototypes, the way libebur128 does it.
+func kWeighting(sampleRate int) [2]*biquad {
+	fs := float64(sampleRate)
+
+	// High shelf
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// High pass
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return [2]*biquad{shelf, highPass}
+}
+
+// Gating blocks are 400 ms long and overlap by 75%.
+const (
+	blockMillis = 400
+	stepMillis  = 100
+)
+
+// Gates of BS.1770: blocks quieter than absoluteGate LUFS, or relativeGate
+// LU below the loudness of the remaining blocks, are ignored.
+const (
+	absoluteGate = -70
+	relativeGate = -10
+)
+
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// Integrated returns the integrated loudness of the samples in LUFS, or
+// -Inf for silence. The samples have the given number of interleaved
+// channels, which are all weighted equally. Sounds shorter than a gating
+// block are measured as a whole.
+func Integrated(samples []float64, channels, sampleRate int) float64 {
+	frames := len(samples) / channels
+	if frames == 0 {
+		return math.Inf(-1)
+	}
+
+	// K-weighted power of every frame, summed over the channels
+	power := make([]float64, frames)
+	for c := 0; c < channels; c++ {
+		filters := kWeighting(sampleRate)
+		for i := range power {
+			s := samples[i*channels+c]
+			s = filters[1].filter(filters[0].filter(s))
+			power[i] += s * s
+		}
+	}
+
+	blockLen := sampleRate * blockMillis / 1000
+	step := sampleRate * stepMillis / 1000
+	if blockLen > frames {
+		blockLen = frames
+	}
+	var blocks []float64
+	for start := 0; start+blockLen <= frames; start += step {
+		sum := 0.0
+		for _, p := range power[start : start+blockLen] {
+			sum += p
+		}
+		blocks = append(blocks, sum/float64(blockLen))
+	}
+
+	gated := func(threshold float64) float64 {
+		sum, n := 0.0, 0
+		for _, b := range blocks {
+			if blockLoudness(b) > threshold {
+				sum += b
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		return sum / float64(n)
+	}
+	mean := gated(absoluteGate)
+	if mean == 0 {
+		return math.Inf(-1)
+	}
+	mean = gated(blockLoudness(mean) + relativeGate)
+	return blockLoudness(mean)
+}
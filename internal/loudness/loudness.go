@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package loudness measures the level of sounds and normalizes it, either
+// to a peak level in dBFS, or to an integrated loudness in LUFS as defined
+// by ITU-R BS.1770 and EBU R128.
+package loudness
+
+import (
+	"fmt"
+	"math"
+)
+
+// Mode selects what Normalize adjusts.
+type Mode int
+
+const (
+	// ModeNone leaves the samples alone.
+	ModeNone Mode = iota
+	// ModePeak scales the samples so that their peak is at the target, in dBFS.
+	ModePeak
+	// ModeLUFS scales the samples so that their integrated loudness is at
+	// the target, in LUFS.
+	ModeLUFS
+)
+
+// DefaultTarget returns a sensible target for mode: -1 dBFS for peaks, or
+// EBU R128's -23 LUFS.
+func DefaultTarget(mode Mode) float64 {
+	if mode == ModeLUFS {
+		return -23
+	}
+	return -1
+}
+
+// Options control how samples are normalized.
+type Options struct {
+	Mode Mode
+	// Target level, in dBFS for ModePeak or LUFS for ModeLUFS
+	Target float64
+}
+
+// Validate checks whether the options are supported.
+func (o Options) Validate() error {
+	if o.Mode < ModeNone || o.Mode > ModeLUFS {
+		return fmt.Errorf("unknown normalization mode %d", o.Mode)
+	}
+	if o.Mode != ModeNone && o.Target > 0 {
+		return fmt.Errorf("target %.1f is above full scale", o.Target)
+	}
+	return nil
+}
+
+func toDB(v float64) float64 {
+	return 20 * math.Log10(v)
+}
+
+func fromDB(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// Peak returns the largest absolute sample in dBFS, or -Inf for silence.
+func Peak(samples []float64) float64 {
+	peak := 0.0
+	for _, s := range samples {
+		peak = math.Max(peak, math.Abs(s))
+	}
+	return toDB(peak)
+}
+
+// Gain returns the factor by which Normalize scales the samples. It is
+// limited so that the peak doesn't exceed 0 dBFS, and is 1 for silence.
+func Gain(samples []float64, channels, sampleRate int, opts Options) float64 {
+	var level float64
+	switch opts.Mode {
+	case ModePeak:
+		level = Peak(samples)
+	case ModeLUFS:
+		level = Integrated(samples, channels, sampleRate)
+	default:
+		return 1
+	}
+	if math.IsInf(level, -1) {
+		return 1
+	}
+	gain := fromDB(opts.Target - level)
+	if peak := Peak(samples); gain > fromDB(-peak) {
+		gain = fromDB(-peak)
+	}
+	return gain
+}
+
+// Normalize returns the samples, which have the given number of interleaved
+// channels, scaled as opts ask for.
+func Normalize(samples []float64, channels, sampleRate int, opts Options) []float64 {
+	gain := Gain(samples, channels, sampleRate, opts)
+	if gain == 1 {
+		return samples
+	}
+	res := make([]float64, len(samples))
+	for i, s := range samples {
+		res[i] = s * gain
+	}
+	return res
+}
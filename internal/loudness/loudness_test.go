@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns a 997 Hz sine, the test signal of BS.1770, with the given
+// amplitude in every channel.
+func sine(amplitude float64, channels, sampleRate int, seconds float64) []float64 {
+	frames := int(seconds * float64(sampleRate))
+	res := make([]float64, frames*channels)
+	for i := 0; i < frames; i++ {
+		s := amplitude * math.Sin(2*math.Pi*997*float64(i)/float64(sampleRate))
+		for c := 0; c < channels; c++ {
+			res[i*channels+c] = s
+		}
+	}
+	return res
+}
+
+func TestPeak(t *testing.T) {
+	tests := []struct {
+		samples []float64
+		want    float64
+	}{
+		{samples: []float64{0.5, -1, 0.25}, want: 0},
+		{samples: []float64{0.5, -0.25}, want: -6.0206},
+		{samples: []float64{0, 0}, want: math.Inf(-1)},
+		{samples: nil, want: math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		got := Peak(tt.samples)
+		if got != tt.want && math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("Peak(%v) = %f, want %f", tt.samples, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrated(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []float64
+		channels int
+		rate     int
+		want     float64
+	}{
+		// A full scale sine in one channel is -3.01 LUFS.
+		{name: "Mono 48 kHz", samples: sine(1, 1, 48000, 5), channels: 1, rate: 48000, want: -3.01},
+		{name: "Mono 44.1 kHz", samples: sine(1, 1, 44100, 5), channels: 1, rate: 44100, want: -3.01},
+		{name: "Stereo", samples: sine(1, 2, 48000, 5), channels: 2, rate: 48000, want: 0},
+		{name: "-20 dB", samples: sine(0.1, 1, 48000, 5), channels: 1, rate: 48000, want: -23.01},
+		{name: "Shorter than a block", samples: sine(1, 1, 48000, 0.2), channels: 1, rate: 48000, want: -3.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Integrated(tt.samples, tt.channels, tt.rate); math.Abs(got-tt.want) > 0.05 {
+				t.Errorf("Integrated() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegratedGating(t *testing.T) {
+	loud := sine(1, 1, 48000, 2)
+	silence := make([]float64, 48000*10)
+	quiet := sine(0.001, 1, 48000, 10)
+	for _, tail := range [][]float64{silence, quiet} {
+		s := append(append([]float64{}, loud...), tail...)
+		// Without gating, the tail would lower the loudness by 7.8 LU. Only
+		// the blocks that overlap the end of the sine are counted.
+		if got := Integrated(s, 1, 48000); math.Abs(got+3.35) > 0.05 {
+			t.Errorf("Integrated() = %f, want -3.35: quiet parts are not gated", got)
+		}
+	}
+	if got := Integrated(silence, 1, 48000); !math.IsInf(got, -1) {
+		t.Errorf("Integrated(silence) = %f, want -Inf", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		opts    Options
+		measure func([]float64) float64
+		want    float64
+	}{
+		{
+			name:    "Peak",
+			samples: sine(0.3, 1, 44100, 1),
+			opts:    Options{Mode: ModePeak, Target: -6},
+			measure: Peak,
+			want:    -6,
+		},
+		{
+			name:    "LUFS",
+			samples: sine(0.3, 1, 44100, 1),
+			opts:    Options{Mode: ModeLUFS, Target: -23},
+			measure: func(s []float64) float64 { return Integrated(s, 1, 44100) },
+			want:    -23,
+		},
+		{
+			name:    "LUFS limited by peak",
+			samples: sine(0.01, 1, 44100, 1),
+			opts:    Options{Mode: ModeLUFS, Target: -1},
+			measure: Peak,
+			want:    0,
+		},
+		{
+			name:    "None",
+			samples: sine(0.3, 1, 44100, 1),
+			opts:    Options{Mode: ModeNone, Target: -6},
+			measure: Peak,
+			want:    -10.458,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.measure(Normalize(tt.samples, 1, 44100, tt.opts))
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("level after Normalize() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+
+	silence := make([]float64, 100)
+	if got := Normalize(silence, 1, 44100, Options{Mode: ModeLUFS, Target: -23}); Peak(got) != math.Inf(-1) {
+		t.Errorf("Normalize() changed silence")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		wantErr bool
+	}{
+		{opts: Options{}, wantErr: false},
+		{opts: Options{Mode: ModePeak, Target: -1}, wantErr: false},
+		{opts: Options{Mode: ModeLUFS, Target: 3}, wantErr: true},
+		{opts: Options{Mode: Mode(7)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: Validate() = %v, wantErr %t", tt.opts, err, tt.wantErr)
+		}
+	}
+}
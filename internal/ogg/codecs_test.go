@@ -0,0 +1,61 @@
+//go:build ogg
+// +build ogg
+
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		magic string
+	}{
+		{name: "vorbis", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 44100}, Quality: 5}, magic: "\x01vorbis"},
+		{name: "vorbis 22kHz", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 22050}, Quality: 0}, magic: "\x01vorbis"},
+		{name: "opus", opts: Options{Codec: CodecOpus, Stream: wav.Stream{SampleRate: 48000}, Quality: 5}, magic: "OpusHead"},
+		{name: "opus 44kHz", opts: Options{Codec: CodecOpus, Stream: wav.Stream{SampleRate: 44100}, Quality: 10}, magic: "OpusHead"},
+		{name: "vorbis stereo", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 44100, Channels: 2}, Quality: 5}, magic: "\x01vorbis"},
+		{name: "opus stereo", opts: Options{Codec: CodecOpus, Stream: wav.Stream{SampleRate: 48000, Channels: 2}, Quality: 5}, magic: "OpusHead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(), tt.opts); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			got := buf.Bytes()
+			if !bytes.HasPrefix(got, []byte("OggS")) {
+				t.Fatalf("Encode() didn't write an Ogg page")
+			}
+			// The first page holds only the identification header.
+			headerLen := 27 + int(got[26])
+			if !bytes.HasPrefix(got[headerLen:], []byte(tt.magic)) {
+				t.Errorf("first packet doesn't start with %q", tt.magic)
+			}
+		})
+	}
+}
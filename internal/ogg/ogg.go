@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+// Package ogg encodes sounds as Ogg Vorbis or Ogg Opus files. It uses
+// libvorbisenc and libopusenc, which are only linked in with the ogg build
+// tag. Without it, Encode returns ErrUnsupported.
+package ogg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+// Codec selects how the sound is compressed.
+type Codec int
+
+const (
+	CodecVorbis Codec = iota
+	CodecOpus
+)
+
+// MaxQuality is the highest supported quality.
+const MaxQuality = 10
+
+// Options control how samples are encoded. Opus always decodes at 48 kHz,
+// so for Opus SampleRate is only the rate that the encoder sees.
+type Options struct {
+	wav.Stream
+	Codec Codec
+	// Quality from 0 (smallest) to MaxQuality (best). It's the same scale
+	// as oggenc's -q, and picks a bit rate for Opus.
+	Quality float64
+}
+
+// Validate checks whether the options are supported.
+func (o Options) Validate() error {
+	if o.Codec != CodecVorbis && o.Codec != CodecOpus {
+		return fmt.Errorf("unsupported codec %d", o.Codec)
+	}
+	if err := o.Stream.Validate(); err != nil {
+		return err
+	}
+	if o.Quality < 0 || o.Quality > MaxQuality {
+		return fmt.Errorf("quality %g is not in [0, %d]", o.Quality, MaxQuality)
+	}
+	return nil
+}
+
+// Extension returns the usual file name extension for the codec.
+func (c Codec) Extension() string {
+	if c == CodecOpus {
+		return ".opus"
+	}
+	return ".ogg"
+}
+
+// opusBitrate maps a quality to an Opus bit rate. Quality 5 gives 76 kbit/s
+// per channel, which is plenty for sound effects.
+func opusBitrate(quality float64, channels int) int {
+	const minBitrate, maxBitrate = 24000, 128000
+	return channels * (minBitrate + int(quality*(maxBitrate-minBitrate)/MaxQuality))
+}
+
+// Encode writes data as an Ogg file to w.
+func Encode(w io.Writer, data []float64, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	channels := opts.NumChannels()
+	resampledData := opts.Prepare(data)
+	samples := make([]float32, len(resampledData))
+	for i, s := range resampledData {
+		samples[i] = float32(s)
+	}
+
+	if opts.Codec == CodecOpus {
+		return encodeOpus(w, samples, channels, opts.SampleRate, opusBitrate(opts.Quality, channels))
+	}
+	return encodeVorbis(w, samples, channels, opts.SampleRate, float32(opts.Quality/MaxQuality))
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+func genSine() []float64 {
+	// 0.1 second of "a"
+	buf := make([]float64, 4410)
+	for i := range buf {
+		buf[i] = math.Sin(math.Pi / 22050.0 * float64(i) * 440)
+	}
+	return buf
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "codec", opts: Options{Codec: 2, Stream: wav.Stream{SampleRate: 44100}, Quality: 5}},
+		{name: "sample rate", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 0}, Quality: 5}},
+		{name: "quality too low", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 44100}, Quality: -1}},
+		{name: "quality too high", opts: Options{Codec: CodecOpus, Stream: wav.Stream{SampleRate: 44100}, Quality: 11}},
+		{name: "channels", opts: Options{Codec: CodecVorbis, Stream: wav.Stream{SampleRate: 44100, Channels: 3}, Quality: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(), tt.opts); err == nil {
+				t.Errorf("Encode() succeeded with %+v", tt.opts)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Encode() wrote %d bytes", buf.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+//go:build ogg
+// +build ogg
+
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+// #cgo pkg-config: libopusenc
+// #include <opusenc.h>
+//
+// // OPUS_SET_BITRATE is a variadic macro, which cgo can't call.
+// static int opus_encoder_set_bitrate(OggOpusEnc *enc, opus_int32 bitrate) {
+//   return ope_encoder_ctl(enc, OPUS_SET_BITRATE(bitrate));
+// }
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+func opusError(what string, code C.int) error {
+	return fmt.Errorf("%s: %s", what, C.GoString(C.ope_strerror(code)))
+}
+
+func encodeOpus(w io.Writer, samples []float32, channels, sampleRate, bitrate int) error {
+	comments := C.ope_comments_create()
+	if comments == nil {
+		return fmt.Errorf("can't create Opus comments")
+	}
+	defer C.ope_comments_destroy(comments)
+
+	var res C.int
+	enc := C.ope_encoder_create_pull(comments, C.opus_int32(sampleRate), C.int(channels), 0, &res)
+	if enc == nil {
+		return opusError("can't initialize Opus encoder", res)
+	}
+	defer C.ope_encoder_destroy(enc)
+
+	if res = C.opus_encoder_set_bitrate(enc, C.opus_int32(bitrate)); res != C.OPE_OK {
+		return opusError("can't set bit rate", res)
+	}
+	if len(samples) > 0 {
+		res = C.ope_encoder_write_float(enc, (*C.float)(unsafe.Pointer(&samples[0])), C.int(len(samples)/channels))
+		if res != C.OPE_OK {
+			return opusError("can't encode", res)
+		}
+	}
+	if res = C.ope_encoder_drain(enc); res != C.OPE_OK {
+		return opusError("can't encode", res)
+	}
+
+	var page *C.uchar
+	var l C.opus_int32
+	for C.ope_encoder_get_page(enc, &page, &l, 1) != 0 {
+		if _, err := w.Write(C.GoBytes(unsafe.Pointer(page), C.int(l))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !ogg
+// +build !ogg
+
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+import (
+	"errors"
+	"io"
+)
+
+// Supported reports whether Encode supports Vorbis and Opus.
+const Supported = false
+
+// ErrUnsupported is returned by Encode when gosfxr was built without the
+// ogg build tag, and thus without libvorbisenc and libopusenc.
+var ErrUnsupported = errors.New("ogg: Vorbis and Opus are not supported in this build")
+
+func encodeOpus(w io.Writer, samples []float32, channels, sampleRate, bitrate int) error {
+	return ErrUnsupported
+}
+
+func encodeVorbis(w io.Writer, samples []float32, channels, sampleRate int, quality float32) error {
+	return ErrUnsupported
+}
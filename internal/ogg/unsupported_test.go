@@ -0,0 +1,38 @@
+//go:build !ogg
+// +build !ogg
+
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/wav"
+)
+
+func TestEncodeUnsupported(t *testing.T) {
+	for _, codec := range []Codec{CodecVorbis, CodecOpus} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, genSine(), Options{Codec: codec, Stream: wav.Stream{SampleRate: 44100}, Quality: 5}); err != ErrUnsupported {
+			t.Errorf("Encode() = %v, want ErrUnsupported", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+//go:build ogg
+// +build ogg
+
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package ogg
+
+// #cgo pkg-config: vorbisenc
+// #include <stdlib.h>
+// #include <vorbis/vorbisenc.h>
+//
+// typedef struct {
+//   vorbis_info vi;
+//   vorbis_comment vc;
+//   vorbis_dsp_state vd;
+//   vorbis_block vb;
+//   ogg_stream_state os;
+//   ogg_page og;
+//   ogg_packet op;
+//   int eos;
+// } vorbis_encoder;
+//
+// static int vorbis_encoder_init(vorbis_encoder *e, int channels, long rate, float quality, int serial) {
+//   ogg_packet header, header_comm, header_code;
+//   int err;
+//
+//   vorbis_info_init(&e->vi);
+//   err = vorbis_encode_init_vbr(&e->vi, channels, rate, quality);
+//   if (err != 0) {
+//     vorbis_info_clear(&e->vi);
+//     return err;
+//   }
+//   vorbis_comment_init(&e->vc);
+//   vorbis_comment_add_tag(&e->vc, "ENCODER", "gosfxr");
+//   vorbis_analysis_init(&e->vd, &e->vi);
+//   vorbis_block_init(&e->vd, &e->vb);
+//   ogg_stream_init(&e->os, serial);
+//
+//   vorbis_analysis_headerout(&e->vd, &e->vc, &header, &header_comm, &header_code);
+//   ogg_stream_packetin(&e->os, &header);
+//   ogg_stream_packetin(&e->os, &header_comm);
+//   ogg_stream_packetin(&e->os, &header_code);
+//   return 0;
+// }
+//
+// // vorbis_encoder_write analyzes n interleaved frames. n == 0 marks the
+// // end of the stream.
+// static void vorbis_encoder_write(vorbis_encoder *e, const float *samples, int n) {
+//   int channels = e->vi.channels;
+//   if (n > 0) {
+//     float **buffer = vorbis_analysis_buffer(&e->vd, n);
+//     int i, c;
+//     for (i = 0; i < n; i++) {
+//       for (c = 0; c < channels; c++) {
+//         buffer[c][i] = samples[i * channels + c];
+//       }
+//     }
+//   }
+//   vorbis_analysis_wrote(&e->vd, n);
+// }
+//
+// // vorbis_encoder_flush_headers puts the headers on pages of their own, as
+// // the spec demands. Returns 1 while there are pages in e->og.
+// static int vorbis_encoder_flush_headers(vorbis_encoder *e) {
+//   return ogg_stream_flush(&e->os, &e->og);
+// }
+//
+// // vorbis_encoder_pageout returns 1 while there are pages in e->og.
+// static int vorbis_encoder_pageout(vorbis_encoder *e) {
+//   for (;;) {
+//     if (ogg_stream_pageout(&e->os, &e->og) != 0) {
+//       return 1;
+//     }
+//     if (e->eos) {
+//       return 0;
+//     }
+//     if (vorbis_bitrate_flushpacket(&e->vd, &e->op) != 0) {
+//       ogg_stream_packetin(&e->os, &e->op);
+//       e->eos = e->op.e_o_s;
+//       continue;
+//     }
+//     if (vorbis_analysis_blockout(&e->vd, &e->vb) != 1) {
+//       return 0;
+//     }
+//     vorbis_analysis(&e->vb, NULL);
+//     vorbis_bitrate_addblock(&e->vb);
+//   }
+// }
+//
+// static void vorbis_encoder_clear(vorbis_encoder *e) {
+//   ogg_stream_clear(&e->os);
+//   vorbis_block_clear(&e->vb);
+//   vorbis_dsp_clear(&e->vd);
+//   vorbis_comment_clear(&e->vc);
+//   vorbis_info_clear(&e->vi);
+// }
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// Number of frames passed to libvorbis at once
+const vorbisChunkSize = 1024
+
+func encodeVorbis(w io.Writer, samples []float32, channels, sampleRate int, quality float32) error {
+	// libvorbis keeps pointers between its structs, so they must live in C memory.
+	e := (*C.vorbis_encoder)(C.calloc(1, C.sizeof_vorbis_encoder))
+	defer C.free(unsafe.Pointer(e))
+	if res := C.vorbis_encoder_init(e, C.int(channels), C.long(sampleRate), C.float(quality), 1); res != 0 {
+		return fmt.Errorf("can't initialize Vorbis encoder (error %d)", int(res))
+	}
+	defer C.vorbis_encoder_clear(e)
+
+	var err error
+	writePage := func() {
+		if err == nil {
+			_, err = w.Write(C.GoBytes(unsafe.Pointer(e.og.header), C.int(e.og.header_len)))
+		}
+		if err == nil {
+			_, err = w.Write(C.GoBytes(unsafe.Pointer(e.og.body), C.int(e.og.body_len)))
+		}
+	}
+
+	for C.vorbis_encoder_flush_headers(e) != 0 {
+		writePage()
+	}
+	frames := len(samples) / channels
+	for start := 0; start < frames; start += vorbisChunkSize {
+		end := start + vorbisChunkSize
+		if end > frames {
+			end = frames
+		}
+		C.vorbis_encoder_write(e, (*C.float)(unsafe.Pointer(&samples[start*channels])), C.int(end-start))
+		for C.vorbis_encoder_pageout(e) != 0 {
+			writePage()
+		}
+	}
+	C.vorbis_encoder_write(e, nil, 0)
+	for C.vorbis_encoder_pageout(e) != 0 {
+		writePage()
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package sequencer strings gosfxr sounds together into short jingles, such
+// as level-up or victory tunes. Sequences are stored as JSON, in files ending
+// in Extension.
+package sequencer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/asig/gosfxr/internal/generator"
+	"github.com/asig/gosfxr/internal/layered"
+)
+
+// Extension of sequence files
+const Extension = ".sfxseq"
+
+// DefaultTempo of sequences, in beats per minute
+const DefaultTempo = 120
+
+// Note plays one of the instruments of a sequence.
+type Note struct {
+	// Index of the instrument in Sequence.Instruments
+	Instrument int `json:"instrument"`
+	// Start of the note in beats after the start of the sequence
+	Beat float64 `json:"beat"`
+	// Pitch offset in semitones
+	Pitch float64 `json:"pitch"`
+	// Gain the note is mixed with
+	Gain float64 `json:"gain"`
+}
+
+// NewNote returns a note that plays instrument unchanged at beat.
+func NewNote(instrument int, beat float64) *Note {
+	return &Note{Instrument: instrument, Beat: beat, Gain: 1}
+}
+
+// UnmarshalJSON decodes a note. Missing values keep their defaults.
+func (n *Note) UnmarshalJSON(j []byte) error {
+	type note Note
+	aux := note(*NewNote(0, 0))
+	if err := json.Unmarshal(j, &aux); err != nil {
+		return err
+	}
+	*n = Note(aux)
+	return nil
+}
+
+// Sequence is a list of notes, each playing one of the instruments.
+type Sequence struct {
+	// Tempo in beats per minute
+	Tempo       float64             `json:"tempo"`
+	Instruments []*generator.Config `json:"instruments"`
+	Notes       []*Note             `json:"notes"`
+}
+
+// New returns an empty sequence with cfg as its only instrument.
+func New(cfg *generator.Config) *Sequence {
+	return &Sequence{Tempo: DefaultTempo, Instruments: []*generator.Config{cfg}}
+}
+
+// UnmarshalJSON decodes a sequence. Missing values keep their defaults,
+// also in the instruments.
+func (s *Sequence) UnmarshalJSON(j []byte) error {
+	type sequence Sequence
+	aux := struct {
+		*sequence
+		Instruments []json.RawMessage `json:"instruments"`
+	}{sequence: &sequence{Tempo: DefaultTempo}}
+	if err := json.Unmarshal(j, &aux); err != nil {
+		return err
+	}
+	aux.sequence.Instruments = nil
+	for _, raw := range aux.Instruments {
+		if string(raw) == "null" {
+			// Left for Validate to reject
+			aux.sequence.Instruments = append(aux.sequence.Instruments, nil)
+			continue
+		}
+		cfg := generator.NewConfig()
+		if err := cfg.InitFromJson(raw); err != nil {
+			return err
+		}
+		aux.sequence.Instruments = append(aux.sequence.Instruments, cfg)
+	}
+	*s = Sequence(*aux.sequence)
+	return nil
+}
+
+func (s *Sequence) InitFromJson(j []byte) error {
+	var res Sequence
+	if err := json.Unmarshal(j, &res); err != nil {
+		return err
+	}
+	if err := res.Validate(); err != nil {
+		return err
+	}
+	*s = res
+	return nil
+}
+
+func (s *Sequence) ToJson() []byte {
+	content, _ := json.MarshalIndent(s, "", "    ")
+	return content
+}
+
+// Validate checks that the sequence can be played.
+func (s *Sequence) Validate() error {
+	if s.Tempo <= 0 {
+		return fmt.Errorf("tempo must be positive")
+	}
+	if len(s.Notes) == 0 {
+		return fmt.Errorf("sequence has no notes")
+	}
+	for i, cfg := range s.Instruments {
+		if cfg == nil {
+			return fmt.Errorf("instrument %d has no configuration", i+1)
+		}
+	}
+	for i, n := range s.Notes {
+		if n == nil {
+			return fmt.Errorf("note %d is missing", i+1)
+		}
+		if n.Instrument < 0 || n.Instrument >= len(s.Instruments) {
+			return fmt.Errorf("note %d plays instrument %d, but there are only %d", i+1, n.Instrument+1, len(s.Instruments))
+		}
+		if n.Beat < 0 {
+			return fmt.Errorf("note %d starts before the sequence", i+1)
+		}
+	}
+	return nil
+}
+
+// Seconds returns the time at which beat starts.
+func (s *Sequence) Seconds(beat float64) float64 {
+	return beat * 60 / s.Tempo
+}
+
+// Sound returns the sequence as a layered sound with a layer for every note.
+func (s *Sequence) Sound() *layered.Sound {
+	sound := &layered.Sound{}
+	for _, n := range s.Notes {
+		sound.Layers = append(sound.Layers, &layered.Layer{
+			Config: s.Instruments[n.Instrument],
+			Offset: s.Seconds(n.Beat),
+			Gain:   n.Gain,
+			Pitch:  n.Pitch,
+		})
+	}
+	return sound
+}
+
+// Render plays all notes and mixes them into a single sound, like
+// layered.Sound.Render does.
+func (s *Sequence) Render(opts generator.Options) (samples []float64, channels int, truncated bool) {
+	return s.Sound().Render(opts)
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sequencer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/asig/gosfxr/internal/generator"
+	"github.com/asig/gosfxr/internal/layered"
+)
+
+// instrument returns a configuration that differs from the default one
+// only in its start frequency.
+func instrument(freq float64) *generator.Config {
+	cfg := generator.NewConfig()
+	cfg.FreqStart = freq
+	return cfg
+}
+
+func TestSound(t *testing.T) {
+	blip := instrument(0.4)
+	coin := instrument(0.6)
+	s := &Sequence{
+		Tempo:       240,
+		Instruments: []*generator.Config{blip, coin},
+		Notes: []*Note{
+			NewNote(0, 0),
+			{Instrument: 0, Beat: 1, Pitch: 7, Gain: 1},
+			{Instrument: 1, Beat: 2.5, Pitch: -12, Gain: 0.5},
+		},
+	}
+	// At 240 bpm, a beat lasts a quarter of a second.
+	want := &layered.Sound{Layers: []*layered.Layer{
+		{Config: blip, Offset: 0, Gain: 1},
+		{Config: blip, Offset: 0.25, Pitch: 7, Gain: 1},
+		{Config: coin, Offset: 0.625, Pitch: -12, Gain: 0.5},
+	}}
+	if got := s.Sound(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sound() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		tempo, beat float64
+		want        float64
+	}{
+		{120, 0, 0},
+		{120, 1, 0.5},
+		{60, 3, 3},
+		{150, 2.5, 1},
+	}
+	for _, tt := range tests {
+		s := &Sequence{Tempo: tt.tempo}
+		if got := s.Seconds(tt.beat); got != tt.want {
+			t.Errorf("Seconds(%f) at %f bpm = %f, want %f", tt.beat, tt.tempo, got, tt.want)
+		}
+	}
+}
+
+func TestJson(t *testing.T) {
+	s := New(instrument(0.3))
+	s.Tempo = 90
+	s.Instruments = append(s.Instruments, instrument(0.5))
+	s.Notes = []*Note{NewNote(0, 0), {Instrument: 1, Beat: 0.5, Pitch: -12, Gain: 0.75}}
+
+	got := &Sequence{}
+	if err := got.InitFromJson(s.ToJson()); err != nil {
+		t.Fatalf("InitFromJson() failed: %s", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("round trip gave %+v, want %+v", got, s)
+	}
+}
+
+func TestJsonDefaults(t *testing.T) {
+	got := &Sequence{}
+	if err := got.InitFromJson([]byte(`{"instruments": [{"base_freq": 0.5}], "notes": [{"beat": 2}]}`)); err != nil {
+		t.Fatalf("InitFromJson() failed: %s", err)
+	}
+	cfg := generator.NewConfig()
+	cfg.FreqStart = 0.5
+	want := New(cfg)
+	want.Notes = []*Note{NewNote(0, 2)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"Valid", `{"instruments": [{}], "notes": [{}]}`, false},
+		{"No notes", `{"instruments": [{}]}`, true},
+		{"No instruments", `{"notes": [{}]}`, true},
+		{"Unknown instrument", `{"instruments": [{}], "notes": [{"instrument": 1}]}`, true},
+		{"Negative beat", `{"instruments": [{}], "notes": [{"beat": -1}]}`, true},
+		{"Null note", `{"instruments": [{}], "notes": [{}, null]}`, true},
+		{"Null instrument", `{"instruments": [{}, null], "notes": [{}]}`, true},
+		{"Zero tempo", `{"tempo": 0, "instruments": [{}], "notes": [{}]}`, true},
+		{"Broken JSON", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Sequence{}).InitFromJson([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InitFromJson() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNumbersFromOne(t *testing.T) {
+	err := (&Sequence{}).InitFromJson([]byte(`{"instruments": [{}], "notes": [{}, {"beat": -1}]}`))
+	if err == nil || err.Error() != "note 2 starts before the sequence" {
+		t.Errorf("InitFromJson() error = %v, want note 2 to start before the sequence", err)
+	}
+}
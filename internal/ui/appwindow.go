@@ -19,10 +19,16 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"math/rand"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gotk3/gotk3/gdk"
@@ -30,19 +36,35 @@ import (
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/veandco/go-sdl2/mix"
 
+	"github.com/asig/gosfxr/internal/edit"
+	"github.com/asig/gosfxr/internal/flac"
 	"github.com/asig/gosfxr/internal/generator"
+	"github.com/asig/gosfxr/internal/layered"
+	"github.com/asig/gosfxr/internal/loudness"
+	"github.com/asig/gosfxr/internal/ogg"
 	"github.com/asig/gosfxr/internal/resources"
 	"github.com/asig/gosfxr/internal/wav"
 )
 
 type AppWindow struct {
+	// The sound being edited, and the index of the layer shown in the controls
+	layers       *layered.Sound
+	currentLayer int
+
+	// Config of the current layer
 	generatorConfig *generator.Config
 	generatedSample []float64
+	// Channels of generatedSample, which is interleaved
+	generatedChannels int
+	referenceSample []float64
+	playingWav      []byte
+	seeds           *rand.Rand
 
 	gtkWindow *gtk.ApplicationWindow
 
 	// Controls
 	btnWaveform             map[generator.Waveform]*gtk.RadioButton
+	checkBandLimited        *gtk.CheckButton
 	adjVolume               *gtk.Adjustment
 	adjEnvelopeAttack       *gtk.Adjustment
 	adjEnvelopeSustain      *gtk.Adjustment
@@ -54,6 +76,7 @@ type AppWindow struct {
 	adFreqDeltaSlide        *gtk.Adjustment
 	adjVibratoDepth         *gtk.Adjustment
 	adjVibratoSpeed         *gtk.Adjustment
+	adjVibratoDelay         *gtk.Adjustment
 	adjArpFreqMult          *gtk.Adjustment
 	adjArpChangeSpeed       *gtk.Adjustment
 	adjDutyCycleCycle       *gtk.Adjustment
@@ -66,15 +89,48 @@ type AppWindow struct {
 	adjLPResonance          *gtk.Adjustment
 	adjHPCutoffFreq         *gtk.Adjustment
 	adjHPCutoffSweep        *gtk.Adjustment
+	adjStereoPan            *gtk.Adjustment
+	adjStereoSpread         *gtk.Adjustment
+	adjStereoDetune         *gtk.Adjustment
+	adjBitCrushDepth        *gtk.Adjustment
+	adjBitCrushSweep        *gtk.Adjustment
+	adjDownsampleFactor     *gtk.Adjustment
+	adjDownsampleSweep      *gtk.Adjustment
+	adjCompThreshold        *gtk.Adjustment
+	adjCompRatio            *gtk.Adjustment
+	adjCompMakeup           *gtk.Adjustment
 	comboExportFreq         *gtk.ComboBox
 	comboExportBits         *gtk.ComboBox
+	comboExportDither       *gtk.ComboBoxText
+	adjExportQuality        *gtk.Adjustment
+	comboNormalize          *gtk.ComboBoxText
+	spinNormalizeTarget     *gtk.SpinButton
+	adjNormalizeTarget      *gtk.Adjustment
+	checkTrim               *gtk.CheckButton
+	spinTrimThreshold       *gtk.SpinButton
+	adjTrimThreshold        *gtk.Adjustment
+	adjFadeIn               *gtk.Adjustment
+	adjFadeOut              *gtk.Adjustment
+	listLayers              *gtk.ListBox
+	btnLayerRemove          *gtk.Button
+	adjLayerOffset          *gtk.Adjustment
+	adjLayerGain            *gtk.Adjustment
+	adjLayerPitch           *gtk.Adjustment
 	imgGeneratedSample      *gtk.Image
+	labelDuration           *gtk.Label
+	unitLabels              []unitLabel
 	statusbar               *gtk.Statusbar
 	nextStatusMsgId         int
 
 	updating bool
 }
 
+// unitLabel shows the value of a slider in physical units.
+type unitLabel struct {
+	label *gtk.Label
+	text  func(cfg *generator.Config) string
+}
+
 func createStatusbar() *gtk.Statusbar {
 	statusbar, _ := gtk.StatusbarNew()
 	return statusbar
@@ -117,8 +173,16 @@ func setRadioButtonImage(btn *gtk.RadioButton, imgName string) {
 	btn.SetImage(loadImageFromPixbuf(loadPixbufFromResource(imgName)))
 }
 
-func (a *AppWindow) applyPreset(presetFunc func()) {
-	presetFunc()
+// nextRand returns a freshly seeded random source and shows the seed in the
+// status bar, so that the result can be reproduced later.
+func (a *AppWindow) nextRand(what string) *rand.Rand {
+	seed := a.seeds.Int63()
+	a.setStatus(fmt.Sprintf("%s, seed %d.", what, seed))
+	return generator.NewRand(seed)
+}
+
+func (a *AppWindow) applyPreset(name string, presetFunc func(*rand.Rand)) {
+	presetFunc(a.nextRand(name))
 	a.updateControls()
 	a.play()
 }
@@ -133,30 +197,39 @@ func (a *AppWindow) toggleWave(btn *gtk.RadioButton, wf generator.Waveform) {
 
 func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 	appWindow := &AppWindow{
+		layers:          layered.New(cfg),
 		generatorConfig: cfg,
+		seeds:           rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 
 	builder, _ := gtk.BuilderNew()
 	builder.AddFromString(uiXMLString)
 
 	builder.ConnectSignals(map[string]interface{}{
-		"btn_waveform_sine_toggled_cb":     func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSine) },
-		"btn_waveform_square_toggled_cb":   func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSquare) },
-		"btn_waveform_sawtooth_toggled_cb": func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSawtooth) },
-		"btn_waveform_noise_toggled_cb":    func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformNoise) },
+		"btn_waveform_sine_toggled_cb":       func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSine) },
+		"btn_waveform_square_toggled_cb":     func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSquare) },
+		"btn_waveform_sawtooth_toggled_cb":   func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformSawtooth) },
+		"btn_waveform_noise_toggled_cb":      func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformNoise) },
+		"btn_waveform_triangle_toggled_cb":   func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformTriangle) },
+		"btn_waveform_breaker_toggled_cb":    func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformBreaker) },
+		"btn_waveform_tan_toggled_cb":        func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformTan) },
+		"btn_waveform_whistle_toggled_cb":    func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformWhistle) },
+		"btn_waveform_pinknoise_toggled_cb":  func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformPinkNoise) },
+		"btn_waveform_brownnoise_toggled_cb": func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformBrownNoise) },
+		"btn_waveform_lfsrnoise_toggled_cb":  func(btn *gtk.RadioButton) { appWindow.toggleWave(btn, generator.WaveformLFSRNoise) },
 
 		// Presets
-		"btn_pickup_clicked_cb":    func() { appWindow.applyPreset(appWindow.generatorConfig.PresetPickup) },
-		"btn_laser_clicked_cb":     func() { appWindow.applyPreset(appWindow.generatorConfig.PresetLaser) },
-		"btn_explosion_clicked_cb": func() { appWindow.applyPreset(appWindow.generatorConfig.PresetExplosion) },
-		"btn_powerup_clicked_cb":   func() { appWindow.applyPreset(appWindow.generatorConfig.PresetPowerup) },
-		"btn_hit_clicked_cb":       func() { appWindow.applyPreset(appWindow.generatorConfig.PresetHit) },
-		"btn_jump_clicked_cb":      func() { appWindow.applyPreset(appWindow.generatorConfig.PresetJump) },
-		"btn_blip_clicked_cb":      func() { appWindow.applyPreset(appWindow.generatorConfig.PresetBlip) },
+		"btn_pickup_clicked_cb":    func() { appWindow.applyPreset("Pickup/Coin", appWindow.generatorConfig.PresetPickup) },
+		"btn_laser_clicked_cb":     func() { appWindow.applyPreset("Laser/Shoot", appWindow.generatorConfig.PresetLaser) },
+		"btn_explosion_clicked_cb": func() { appWindow.applyPreset("Explosion", appWindow.generatorConfig.PresetExplosion) },
+		"btn_powerup_clicked_cb":   func() { appWindow.applyPreset("Powerup", appWindow.generatorConfig.PresetPowerup) },
+		"btn_hit_clicked_cb":       func() { appWindow.applyPreset("Hit/Hurt", appWindow.generatorConfig.PresetHit) },
+		"btn_jump_clicked_cb":      func() { appWindow.applyPreset("Jump", appWindow.generatorConfig.PresetJump) },
+		"btn_blip_clicked_cb":      func() { appWindow.applyPreset("Blip/Select", appWindow.generatorConfig.PresetBlip) },
 
 		// Automated adjustments
-		"btn_mutate_clicked_cb":    func() { appWindow.generatorConfig.Mutate(); appWindow.updateControls() },
-		"btn_randomize_clicked_cb": func() { appWindow.generatorConfig.Randomize(); appWindow.updateControls() },
+		"btn_mutate_clicked_cb":    func() { appWindow.generatorConfig.Mutate(appWindow.nextRand("Mutate")); appWindow.updateControls() },
+		"btn_randomize_clicked_cb": func() { appWindow.generatorConfig.Randomize(appWindow.nextRand("Randomize")); appWindow.updateControls() },
 
 		// Other buttons
 		"btn_play_clicked_cb":   func() { appWindow.play() },
@@ -164,9 +237,34 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 		"btn_save_clicked_cb":   func() { appWindow.save() },
 		"btn_export_clicked_cb": func() { appWindow.export() },
 
+		"btn_reference_clicked_cb": func() { appWindow.loadReference() },
+
+		"combo_normalize_changed_cb":   func() { appWindow.normalizeModeChanged() },
+		"check_trim_toggled_cb":        func() { appWindow.spinTrimThreshold.SetSensitive(appWindow.checkTrim.GetActive()) },
+		"check_bandlimited_toggled_cb": func() { appWindow.updateControls() },
+
+		// Layers
+		"list_layers_row_selected_cb":       func() { appWindow.layerSelected() },
+		"btn_layer_add_clicked_cb":          func() { appWindow.addLayer() },
+		"btn_layer_remove_clicked_cb":       func() { appWindow.removeLayer() },
+		"adj_layer_offset_value_changed_cb": func(adj *gtk.Adjustment) { appWindow.layer().Offset = adj.GetValue() / 1000; appWindow.updateControls() },
+		"adj_layer_gain_value_changed_cb":   func(adj *gtk.Adjustment) { appWindow.layer().Gain = adj.GetValue(); appWindow.updateControls() },
+		"adj_layer_pitch_value_changed_cb":  func(adj *gtk.Adjustment) { appWindow.layer().Pitch = adj.GetValue(); appWindow.updateControls() },
+
+		// Clipboard
+		"btn_copy_link_clicked_cb": func() { appWindow.copyLink() },
+		"btn_paste_clicked_cb":     func() { appWindow.paste() },
+
 		// Sliders
 		"adj_arp_changespeed_value_changed_cb":        func(adj *gtk.Adjustment) { appWindow.generatorConfig.ArpChangeSpeed = adj.GetValue(); appWindow.updateControls() },
 		"adj_arp_freqmult_value_changed_cb":           func(adj *gtk.Adjustment) { appWindow.generatorConfig.ArpFreqMult = adj.GetValue(); appWindow.updateControls() },
+		"adj_bitcrush_depth_value_changed_cb":         func(adj *gtk.Adjustment) { appWindow.generatorConfig.BitCrush = adj.GetValue(); appWindow.updateControls() },
+		"adj_bitcrush_sweep_value_changed_cb":         func(adj *gtk.Adjustment) { appWindow.generatorConfig.BitCrushSweep = adj.GetValue(); appWindow.updateControls() },
+		"adj_comp_makeup_value_changed_cb":            func(adj *gtk.Adjustment) { appWindow.generatorConfig.CompMakeup = adj.GetValue(); appWindow.updateControls() },
+		"adj_comp_ratio_value_changed_cb":             func(adj *gtk.Adjustment) { appWindow.generatorConfig.CompRatio = adj.GetValue(); appWindow.updateControls() },
+		"adj_comp_threshold_value_changed_cb":         func(adj *gtk.Adjustment) { appWindow.generatorConfig.CompThreshold = adj.GetValue(); appWindow.updateControls() },
+		"adj_downsample_factor_value_changed_cb":      func(adj *gtk.Adjustment) { appWindow.generatorConfig.Downsample = adj.GetValue(); appWindow.updateControls() },
+		"adj_downsample_sweep_value_changed_cb":       func(adj *gtk.Adjustment) { appWindow.generatorConfig.DownsampleSweep = adj.GetValue(); appWindow.updateControls() },
 		"adj_dutycycle_cycle_value_changed_cb":        func(adj *gtk.Adjustment) { appWindow.generatorConfig.DutyCycle = adj.GetValue(); appWindow.updateControls() },
 		"adj_dutycycle_sweep_value_changed_cb":        func(adj *gtk.Adjustment) { appWindow.generatorConfig.DutyCycleSweep = adj.GetValue(); appWindow.updateControls() },
 		"adj_envelope_attack_value_changed_cb":        func(adj *gtk.Adjustment) { appWindow.generatorConfig.EnvelopeAttack = adj.GetValue(); appWindow.updateControls() },
@@ -185,22 +283,73 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 		"adj_phaser_offset_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.PhaserOffset = adj.GetValue(); appWindow.updateControls() },
 		"adj_phaser_sweep_value_changed_cb":           func(adj *gtk.Adjustment) { appWindow.generatorConfig.PhaserSweep = adj.GetValue(); appWindow.updateControls() },
 		"adj_repeat_rate_value_changed_cb":            func(adj *gtk.Adjustment) { appWindow.generatorConfig.RepeatRate = adj.GetValue(); appWindow.updateControls() },
+		"adj_stereo_detune_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.StereoDetune = adj.GetValue(); appWindow.updateControls() },
+		"adj_stereo_pan_value_changed_cb":             func(adj *gtk.Adjustment) { appWindow.generatorConfig.Pan = adj.GetValue(); appWindow.updateControls() },
+		"adj_stereo_spread_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.StereoSpread = adj.GetValue(); appWindow.updateControls() },
 		"adj_vibrato_depth_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.VibDepth = adj.GetValue(); appWindow.updateControls() },
 		"adj_vibrato_speed_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.VibSpeed = adj.GetValue(); appWindow.updateControls() },
+		"adj_vibrato_delay_value_changed_cb":          func(adj *gtk.Adjustment) { appWindow.generatorConfig.VibDelay = adj.GetValue(); appWindow.updateControls() },
 		"adj_volume_value_changed_cb":                 func(adj *gtk.Adjustment) { appWindow.generatorConfig.Volume = adj.GetValue(); appWindow.updateControls() },
 	})
 
 	appWindow.gtkWindow = getObj(builder, "application_window").(*gtk.ApplicationWindow)
 
 	appWindow.imgGeneratedSample = getObj(builder, "img_generated_sample").(*gtk.Image)
+	appWindow.labelDuration = getObj(builder, "label_duration").(*gtk.Label)
+	appWindow.unitLabels = []unitLabel{
+		{getObj(builder, "label_freq_start_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatHz(generator.FreqToHz(c.FreqStart))
+		}},
+		{getObj(builder, "label_freq_min_cutoff_unit").(*gtk.Label), func(c *generator.Config) string {
+			if c.FreqMinCutoff == 0 {
+				return "off"
+			}
+			return formatHz(generator.FreqToHz(c.FreqMinCutoff))
+		}},
+		{getObj(builder, "label_freq_slide_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatOctaves(generator.SlideToOctaves(c.FreqSlide))
+		}},
+		{getObj(builder, "label_envelope_attack_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatDuration(generator.EnvelopeToDuration(c.EnvelopeAttack))
+		}},
+		{getObj(builder, "label_envelope_sustain_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatDuration(generator.EnvelopeToDuration(c.EnvelopeSustain))
+		}},
+		{getObj(builder, "label_envelope_decay_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatDuration(generator.EnvelopeToDuration(c.EnvelopeDecay))
+		}},
+		{getObj(builder, "label_vibrato_speed_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatHz(generator.VibSpeedToHz(c.VibSpeed))
+		}},
+		{getObj(builder, "label_vibrato_delay_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatDuration(generator.EnvelopeToDuration(c.VibDelay))
+		}},
+		{getObj(builder, "label_arp_freqmult_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatSemitones(generator.ArpMultToSemitones(c.ArpFreqMult))
+		}},
+		{getObj(builder, "label_arp_changespeed_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatPeriod(generator.ArpSpeedToDuration(c.ArpChangeSpeed))
+		}},
+		{getObj(builder, "label_repeat_rate_unit").(*gtk.Label), func(c *generator.Config) string {
+			return formatPeriod(generator.RepeatRateToDuration(c.RepeatRate))
+		}},
+	}
 
 	// Controls
 	appWindow.btnWaveform = map[generator.Waveform]*gtk.RadioButton{
-		generator.WaveformSquare:   getObj(builder, "btn_waveform_square").(*gtk.RadioButton),
-		generator.WaveformSawtooth: getObj(builder, "btn_waveform_sawtooth").(*gtk.RadioButton),
-		generator.WaveformSine:     getObj(builder, "btn_waveform_sine").(*gtk.RadioButton),
-		generator.WaveformNoise:    getObj(builder, "btn_waveform_noise").(*gtk.RadioButton),
+		generator.WaveformSquare:     getObj(builder, "btn_waveform_square").(*gtk.RadioButton),
+		generator.WaveformSawtooth:   getObj(builder, "btn_waveform_sawtooth").(*gtk.RadioButton),
+		generator.WaveformSine:       getObj(builder, "btn_waveform_sine").(*gtk.RadioButton),
+		generator.WaveformNoise:      getObj(builder, "btn_waveform_noise").(*gtk.RadioButton),
+		generator.WaveformTriangle:   getObj(builder, "btn_waveform_triangle").(*gtk.RadioButton),
+		generator.WaveformBreaker:    getObj(builder, "btn_waveform_breaker").(*gtk.RadioButton),
+		generator.WaveformTan:        getObj(builder, "btn_waveform_tan").(*gtk.RadioButton),
+		generator.WaveformWhistle:    getObj(builder, "btn_waveform_whistle").(*gtk.RadioButton),
+		generator.WaveformPinkNoise:  getObj(builder, "btn_waveform_pinknoise").(*gtk.RadioButton),
+		generator.WaveformBrownNoise: getObj(builder, "btn_waveform_brownnoise").(*gtk.RadioButton),
+		generator.WaveformLFSRNoise:  getObj(builder, "btn_waveform_lfsrnoise").(*gtk.RadioButton),
 	}
+	appWindow.checkBandLimited = getObj(builder, "check_bandlimited").(*gtk.CheckButton)
 	appWindow.adjVolume = getObj(builder, "adj_volume").(*gtk.Adjustment)
 	appWindow.adjEnvelopeAttack = getObj(builder, "adj_envelope_attack").(*gtk.Adjustment)
 	appWindow.adjEnvelopeSustain = getObj(builder, "adj_envelope_sustain").(*gtk.Adjustment)
@@ -212,6 +361,7 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 	appWindow.adFreqDeltaSlide = getObj(builder, "adj_freq_delta_slide").(*gtk.Adjustment)
 	appWindow.adjVibratoDepth = getObj(builder, "adj_vibrato_depth").(*gtk.Adjustment)
 	appWindow.adjVibratoSpeed = getObj(builder, "adj_vibrato_speed").(*gtk.Adjustment)
+	appWindow.adjVibratoDelay = getObj(builder, "adj_vibrato_delay").(*gtk.Adjustment)
 	appWindow.adjArpFreqMult = getObj(builder, "adj_arp_freqmult").(*gtk.Adjustment)
 	appWindow.adjArpChangeSpeed = getObj(builder, "adj_arp_changespeed").(*gtk.Adjustment)
 	appWindow.adjDutyCycleCycle = getObj(builder, "adj_dutycycle_cycle").(*gtk.Adjustment)
@@ -224,8 +374,33 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 	appWindow.adjLPResonance = getObj(builder, "adj_lp_resonance").(*gtk.Adjustment)
 	appWindow.adjHPCutoffFreq = getObj(builder, "adj_hp_cutoff_freq").(*gtk.Adjustment)
 	appWindow.adjHPCutoffSweep = getObj(builder, "adj_hp_cutoff_sweep").(*gtk.Adjustment)
+	appWindow.adjStereoPan = getObj(builder, "adj_stereo_pan").(*gtk.Adjustment)
+	appWindow.adjStereoSpread = getObj(builder, "adj_stereo_spread").(*gtk.Adjustment)
+	appWindow.adjStereoDetune = getObj(builder, "adj_stereo_detune").(*gtk.Adjustment)
+	appWindow.adjBitCrushDepth = getObj(builder, "adj_bitcrush_depth").(*gtk.Adjustment)
+	appWindow.adjBitCrushSweep = getObj(builder, "adj_bitcrush_sweep").(*gtk.Adjustment)
+	appWindow.adjDownsampleFactor = getObj(builder, "adj_downsample_factor").(*gtk.Adjustment)
+	appWindow.adjDownsampleSweep = getObj(builder, "adj_downsample_sweep").(*gtk.Adjustment)
+	appWindow.adjCompThreshold = getObj(builder, "adj_comp_threshold").(*gtk.Adjustment)
+	appWindow.adjCompRatio = getObj(builder, "adj_comp_ratio").(*gtk.Adjustment)
+	appWindow.adjCompMakeup = getObj(builder, "adj_comp_makeup").(*gtk.Adjustment)
 	appWindow.comboExportFreq = getObj(builder, "combo_frequency").(*gtk.ComboBox)
 	appWindow.comboExportBits = getObj(builder, "combo_bits").(*gtk.ComboBox)
+	appWindow.comboExportDither = getObj(builder, "combo_dither").(*gtk.ComboBoxText)
+	appWindow.adjExportQuality = getObj(builder, "adj_quality").(*gtk.Adjustment)
+	appWindow.comboNormalize = getObj(builder, "combo_normalize").(*gtk.ComboBoxText)
+	appWindow.spinNormalizeTarget = getObj(builder, "spin_normalize_target").(*gtk.SpinButton)
+	appWindow.adjNormalizeTarget = getObj(builder, "adj_normalize_target").(*gtk.Adjustment)
+	appWindow.checkTrim = getObj(builder, "check_trim").(*gtk.CheckButton)
+	appWindow.spinTrimThreshold = getObj(builder, "spin_trim_threshold").(*gtk.SpinButton)
+	appWindow.adjTrimThreshold = getObj(builder, "adj_trim_threshold").(*gtk.Adjustment)
+	appWindow.adjFadeIn = getObj(builder, "adj_fade_in").(*gtk.Adjustment)
+	appWindow.adjFadeOut = getObj(builder, "adj_fade_out").(*gtk.Adjustment)
+	appWindow.listLayers = getObj(builder, "list_layers").(*gtk.ListBox)
+	appWindow.btnLayerRemove = getObj(builder, "btn_layer_remove").(*gtk.Button)
+	appWindow.adjLayerOffset = getObj(builder, "adj_layer_offset").(*gtk.Adjustment)
+	appWindow.adjLayerGain = getObj(builder, "adj_layer_gain").(*gtk.Adjustment)
+	appWindow.adjLayerPitch = getObj(builder, "adj_layer_pitch").(*gtk.Adjustment)
 	appWindow.statusbar = getObj(builder, "statusbar").(*gtk.Statusbar)
 
 	// Set images
@@ -233,6 +408,13 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 	appWindow.btnWaveform[generator.WaveformSawtooth].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_sawtooth.png")))
 	appWindow.btnWaveform[generator.WaveformSine].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_sine.png")))
 	appWindow.btnWaveform[generator.WaveformNoise].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_noise.png")))
+	appWindow.btnWaveform[generator.WaveformTriangle].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_triangle.png")))
+	appWindow.btnWaveform[generator.WaveformBreaker].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_breaker.png")))
+	appWindow.btnWaveform[generator.WaveformTan].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_tan.png")))
+	appWindow.btnWaveform[generator.WaveformWhistle].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_whistle.png")))
+	appWindow.btnWaveform[generator.WaveformPinkNoise].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_pinknoise.png")))
+	appWindow.btnWaveform[generator.WaveformBrownNoise].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_brownnoise.png")))
+	appWindow.btnWaveform[generator.WaveformLFSRNoise].SetImage(loadImageFromPixbuf(loadPixbufFromResource("resources/waveforms/waveform_lfsrnoise.png")))
 
 	setBtnImage(builder, "btn_play", "resources/16px/play-solid.png")
 	setBtnImage(builder, "btn_load", "resources/16px/upload-solid.png")
@@ -243,6 +425,7 @@ func NewAppWindow(a *gtk.Application, cfg *generator.Config) *AppWindow {
 
 	appWindow.comboExportFreq.SetActive(0)
 	appWindow.comboExportBits.SetActive(1)
+	appWindow.updateLayerList()
 
 	pb, err := gdk.PixbufNewFromBytesOnly(resources.Find("resources/icons/icon.svg"))
 	if err != nil {
@@ -278,9 +461,20 @@ func (a *AppWindow) setStatus(msg string) {
 }
 
 func (a *AppWindow) play() {
-	wavData := wav.Generate(a.generatedSample, 16, 44100)
-	chunk, _ := mix.QuickLoadWAV(wavData)
-	chunk.Play(-1, 0)
+	var buf bytes.Buffer
+	err := wav.Encode(&buf, a.generatedSample, wav.Options{Stream: wav.Stream{SampleRate: wav.SourceFreq, Channels: a.generatedChannels}, Bits: 16})
+	var chunk *mix.Chunk
+	if err == nil {
+		// SDL doesn't copy the data, so keep it alive while it's playing.
+		a.playingWav = buf.Bytes()
+		chunk, err = mix.QuickLoadWAV(a.playingWav)
+	}
+	if err == nil {
+		_, err = chunk.Play(-1, 0)
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't play sound: %s", err))
+	}
 }
 
 func makeFilter(name string, patterns ...string) *gtk.FileFilter {
@@ -300,80 +494,330 @@ func fixExtensions(filename, ext string) string {
 	return filename + ext
 }
 
-func (a *AppWindow) fileDialog(title string, action gtk.FileChooserAction, okBtnTitle string, filter *gtk.FileFilter) (string, bool) {
+// fileDialog asks for a file name. It also returns the index of the filter
+// that was selected, or -1 for "All files".
+func (a *AppWindow) fileDialog(title string, action gtk.FileChooserAction, okBtnTitle string, filters ...*gtk.FileFilter) (string, int, bool) {
 	dlg, _ := gtk.FileChooserDialogNewWith2Buttons(title, a.gtkWindow, action, okBtnTitle, gtk.RESPONSE_ACCEPT, "Cancel", gtk.RESPONSE_CANCEL)
 	defer dlg.Destroy()
 
-	dlg.AddFilter(filter)
+	for _, filter := range filters {
+		dlg.AddFilter(filter)
+	}
 	dlg.AddFilter(makeFilter("All files", "*.*"))
 	res := dlg.Run()
 	filename := dlg.GetFilename()
-	return filename, res == gtk.RESPONSE_ACCEPT
+
+	// gotk3 doesn't wrap gtk_file_chooser_get_filter, but the property
+	// holds the same.
+	selected := -1
+	if prop, err := dlg.GetProperty("filter"); err == nil {
+		if obj, ok := prop.(*glib.Object); ok && obj != nil {
+			for i, filter := range filters {
+				if obj.Native() == filter.Native() {
+					selected = i
+				}
+			}
+		}
+	}
+	return filename, selected, res == gtk.RESPONSE_ACCEPT
+}
+
+// filterExtension returns the extension for files saved with the given
+// filter. exts holds the extension of each filter; the first one is used
+// for "All files".
+func filterExtension(exts []string, filter int) string {
+	if filter < 0 || filter >= len(exts) {
+		return exts[0]
+	}
+	return exts[filter]
+}
+
+func isSfsFile(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".sfs"
+}
+
+func isLayeredFile(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == layered.Extension
 }
 
 func (a *AppWindow) load() {
-	filename, ok := a.fileDialog("Load configuration", gtk.FILE_CHOOSER_ACTION_OPEN, "Load", makeFilter("Configs", "*.json"))
+	filename, _, ok := a.fileDialog("Load configuration", gtk.FILE_CHOOSER_ACTION_OPEN, "Load",
+		makeFilter("Configs", "*.json", "*.sfxl", "*.sfs"),
+		makeFilter("gosfxr configs", "*.json"),
+		makeFilter("Layered sounds", "*.sfxl"),
+		makeFilter("sfxr settings", "*.sfs"))
 	if !ok {
 		return
 	}
 
-	content, _ := ioutil.ReadFile(filename)
-	a.generatorConfig.InitFromJson(content)
+	// Layered sounds replace all layers, everything else just the current one.
+	content, err := ioutil.ReadFile(filename)
+	if err == nil {
+		switch {
+		case isLayeredFile(filename):
+			err = a.layers.InitFromJson(content)
+			if err == nil {
+				a.currentLayer = 0
+				a.generatorConfig = a.layer().Config
+				a.updateLayerList()
+			}
+		case isSfsFile(filename):
+			err = a.generatorConfig.InitFromSfs(content)
+		default:
+			err = a.generatorConfig.InitFromJson(content)
+		}
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't read configuration from %s: %s", filename, err))
+		return
+	}
 	a.updateControls()
 	a.setStatus(fmt.Sprintf("Configuration read from %s.", filename))
 
 }
 
 func (a *AppWindow) save() {
-	filename, ok := a.fileDialog("Save configuration", gtk.FILE_CHOOSER_ACTION_SAVE, "Save", makeFilter("Configs", "*.json"))
+	filename, filter, ok := a.fileDialog("Save configuration", gtk.FILE_CHOOSER_ACTION_SAVE, "Save",
+		makeFilter("gosfxr configs", "*.json"),
+		makeFilter("Layered sounds", "*.sfxl"),
+		makeFilter("sfxr settings", "*.sfs"))
 	if !ok {
 		return
 	}
-	filename = fixExtensions(filename, ".json")
-	ioutil.WriteFile(filename, a.generatorConfig.ToJson(), 0644)
+	filename = fixExtensions(filename, filterExtension([]string{".json", layered.Extension, ".sfs"}, filter))
+
+	// Layered sounds store all layers, everything else just the current one.
+	content := a.generatorConfig.ToJson()
+	var err error
+	if isLayeredFile(filename) {
+		content = a.layers.ToJson()
+	} else if isSfsFile(filename) {
+		content, err = a.generatorConfig.ToSfs(generator.SfsVersion)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filename, content, 0644)
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't write configuration to %s: %s", filename, err))
+		return
+	}
 	a.setStatus(fmt.Sprintf("Configuration written to %s.", filename))
 }
 
-func getComboInt(combo *gtk.ComboBox) int {
-	iter, _ := combo.GetActiveIter()
-	tm, _ := combo.GetModel()
-	val, _ := tm.ToTreeModel().GetValue(iter, 0)
-	gv, _ := val.GoValue()
-	return gv.(int)
+// layer returns the layer shown in the controls.
+func (a *AppWindow) layer() *layered.Layer {
+	return a.layers.Layers[a.currentLayer]
+}
+
+// selectLayer shows layer i in the controls.
+func (a *AppWindow) selectLayer(i int) {
+	a.currentLayer = i
+	a.generatorConfig = a.layer().Config
+	a.updateControls()
+}
+
+func (a *AppWindow) layerSelected() {
+	if a.updating {
+		return
+	}
+	// Rows are deselected while the list is rebuilt.
+	row := a.listLayers.GetSelectedRow()
+	if row == nil {
+		return
+	}
+	a.selectLayer(row.GetIndex())
+}
+
+func (a *AppWindow) addLayer() {
+	a.layers.Layers = append(a.layers.Layers, layered.NewLayer(generator.NewConfig()))
+	a.updateLayerList()
+	a.selectLayer(len(a.layers.Layers) - 1)
+}
+
+func (a *AppWindow) removeLayer() {
+	if len(a.layers.Layers) < 2 {
+		return
+	}
+	a.layers.Layers = append(a.layers.Layers[:a.currentLayer], a.layers.Layers[a.currentLayer+1:]...)
+	if a.currentLayer == len(a.layers.Layers) {
+		a.currentLayer--
+	}
+	a.updateLayerList()
+	a.selectLayer(a.currentLayer)
+}
+
+// updateLayerList creates a row for every layer. The current layer is
+// selected by updateControls.
+func (a *AppWindow) updateLayerList() {
+	if children := a.listLayers.GetChildren(); children != nil {
+		children.Foreach(func(item interface{}) {
+			a.listLayers.Remove(item.(gtk.IWidget))
+		})
+	}
+	for i := range a.layers.Layers {
+		label, _ := gtk.LabelNew(fmt.Sprintf("Layer %d", i+1))
+		label.SetXAlign(0)
+		a.listLayers.Add(label)
+	}
+	a.listLayers.ShowAll()
+}
+
+func (a *AppWindow) copyLink() {
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't access clipboard: %s", err))
+		return
+	}
+	clipboard.SetText(generator.JsfxrURL + a.generatorConfig.ToJsfxrString())
+	a.setStatus("jsfxr link copied to clipboard.")
+}
+
+func (a *AppWindow) paste() {
+	clipboard, err := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	var text string
+	if err == nil {
+		text, err = clipboard.WaitForText()
+	}
+	if err == nil {
+		err = a.generatorConfig.InitFromJsfxr(text)
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't paste sound: %s", err))
+		return
+	}
+	a.updateControls()
+	a.setStatus("Sound pasted from clipboard.")
+}
+
+func getComboInt(combo *gtk.ComboBox) (int, error) {
+	if iter, err := combo.GetActiveIter(); err == nil {
+		tm, _ := combo.GetModel()
+		val, _ := tm.ToTreeModel().GetValue(iter, 0)
+		gv, _ := val.GoValue()
+		return gv.(int), nil
+	}
+
+	// No row is selected, so the user entered a custom value.
+	entry, err := combo.GetEntry()
+	if err != nil {
+		return 0, err
+	}
+	text, err := entry.GetText()
+	if err != nil {
+		return 0, err
+	}
+	return parseComboInt(text)
+}
+
+// parseComboInt parses a value entered in a combo box, e.g. "48000 Hz".
+func parseComboInt(text string) (int, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("no value entered")
+	}
+	val, err := strconv.Atoi(fields[0])
+	if err != nil || val <= 0 {
+		return 0, fmt.Errorf("invalid value %q", fields[0])
+	}
+	return val, nil
+}
+
+// exportCodecs maps file name extensions to the codecs of compressed exports.
+var exportCodecs = map[string]ogg.Codec{
+	".ogg":  ogg.CodecVorbis,
+	".opus": ogg.CodecOpus,
 }
 
 func (a *AppWindow) export() {
-	filename, ok := a.fileDialog("Export to WAV", gtk.FILE_CHOOSER_ACTION_SAVE, "Export", makeFilter("WAV files", "*.wav"))
+	filename, filter, ok := a.fileDialog("Export sound", gtk.FILE_CHOOSER_ACTION_SAVE, "Export",
+		makeFilter("WAV files", "*.wav"),
+		makeFilter("FLAC files", "*.flac"),
+		makeFilter("Ogg Vorbis files", "*.ogg"),
+		makeFilter("Opus files", "*.opus"))
 	if !ok {
 		return
 	}
-	filename = fixExtensions(filename, ".wav")
+	filename = fixExtensions(filename, filterExtension([]string{".wav", ".flac", ".ogg", ".opus"}, filter))
 
-	bits := getComboInt(a.comboExportBits)
-	freq := getComboInt(a.comboExportFreq)
-	wav := wav.Generate(a.generatedSample, bits, freq)
-	ioutil.WriteFile(filename, wav, 0644)
-	a.setStatus(fmt.Sprintf("WAV exported to %s.", filename))
+	bits, _ := getComboInt(a.comboExportBits)
+	freq, err := getComboInt(a.comboExportFreq)
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Invalid sample rate: %s", err))
+		return
+	}
+	normalize := loudness.Options{
+		Mode:   loudness.Mode(a.comboNormalize.GetActive()), // Items are in the same order as the constants
+		Target: a.adjNormalizeTarget.GetValue(),
+	}
+	edits := edit.Options{
+		Trim:          a.checkTrim.GetActive(),
+		TrimThreshold: a.adjTrimThreshold.GetValue(),
+		FadeIn:        time.Duration(a.adjFadeIn.GetValue() * float64(time.Millisecond)),
+		FadeOut:       time.Duration(a.adjFadeOut.GetValue() * float64(time.Millisecond)),
+	}
+	// Render the sound again at the export's sample rate, so it doesn't
+	// need to be resampled.
+	opts := a.generatorOptions()
+	opts.SampleRate = freq
+	sample, channels, _ := a.layers.Render(opts)
+	sample = edit.Apply(sample, channels, freq, edits)
+	sample = loudness.Normalize(sample, channels, freq, normalize)
+
+	var buf bytes.Buffer
+	ext := strings.ToLower(filepath.Ext(filename))
+	dither := wav.Dither(a.comboExportDither.GetActive()) // Items are in the same order as the constants
+	if codec, ok := exportCodecs[ext]; ok {
+		err = ogg.Encode(&buf, sample, ogg.Options{Codec: codec, Stream: wav.Stream{SampleRate: freq, SourceRate: freq, Channels: channels}, Quality: a.adjExportQuality.GetValue()})
+	} else if ext == ".flac" {
+		err = flac.Encode(&buf, sample, flac.Options{Stream: wav.Stream{SampleRate: freq, SourceRate: freq, Channels: channels}, Bits: bits, Dither: dither})
+	} else {
+		err = wav.Encode(&buf, sample, wav.Options{Stream: wav.Stream{SampleRate: freq, SourceRate: freq, Channels: channels}, Bits: bits, Dither: dither})
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't export sound to %s: %s", filename, err))
+		return
+	}
+	a.setStatus(fmt.Sprintf("Sound exported to %s.", filename))
 }
 
-func (a *AppWindow) updateGeneratedSampleImage(sample []float64) {
-	alloc := a.imgGeneratedSample.GetAllocation()
-	w := alloc.GetWidth()
-	h := alloc.GetHeight()
+// normalizeModeChanged enables the target for the chosen normalization, and
+// resets it to the mode's default.
+func (a *AppWindow) normalizeModeChanged() {
+	mode := loudness.Mode(a.comboNormalize.GetActive())
+	a.spinNormalizeTarget.SetSensitive(mode != loudness.ModeNone)
+	a.adjNormalizeTarget.SetValue(loudness.DefaultTarget(mode))
+}
 
-	imgdata := make([]byte, w*h*3, w*h*3)
-	stride := w * 3
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			imgdata[y*stride+3*x+0] = byte(x & 0xff)
-			imgdata[y*stride+3*x+1] = byte(y & 0xff)
-			imgdata[y*stride+3*x+2] = 0
-		}
+func (a *AppWindow) loadReference() {
+	filename, _, ok := a.fileDialog("Load reference sound", gtk.FILE_CHOOSER_ACTION_OPEN, "Load", makeFilter("WAV files", "*.wav"))
+	if !ok {
+		return
 	}
 
-	for idx, _ := range imgdata {
-		imgdata[idx] = 255
+	f, err := os.Open(filename)
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't read reference sound from %s: %s", filename, err))
+		return
 	}
+	defer f.Close()
+	sample, format, err := wav.Decode(f)
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Can't read reference sound from %s: %s", filename, err))
+		return
+	}
+	sample = wav.Downmix(sample, format.Channels)
+	a.referenceSample = wav.Resample(sample, format.SampleRate, wav.SourceFreq)
+	a.updateGeneratedSampleImage(a.generatedSample)
+	a.setStatus(fmt.Sprintf("Reference sound read from %s.", filename))
+}
+
+// plotSample draws sample into imgdata. The x axis spans l samples, so that
+// samples of different lengths can be compared.
+func plotSample(imgdata []byte, w, h int, sample []float64, l int, r, g, b byte) {
+	stride := w * 3
 
 	// find min/max so that we can scale appropriately
 	minS := 1.0
@@ -388,12 +832,18 @@ func (a *AppWindow) updateGeneratedSampleImage(sample []float64) {
 	}
 	scale := 2.0/(maxS - minS)
 
-	bufstep := float64(len(sample)) / float64(w)
+	bufstep := float64(l) / float64(w)
 	for x := 0; x < w; x++ {
 		// Interpolate all values
 		p1 := int(float64(x) * bufstep)
 		p2 := int(float64(x+1) * bufstep)
+		if p2 > len(sample) {
+			break
+		}
 		d := p2 - p1
+		if d == 0 {
+			continue
+		}
 		s := 0.0
 		for p1 < p2 {
 			s = s + sample[p1]
@@ -408,10 +858,33 @@ func (a *AppWindow) updateGeneratedSampleImage(sample []float64) {
 		if y >= h {
 			y = h - 1
 		}
-		imgdata[y*stride+3*x+0] = 0
-		imgdata[y*stride+3*x+1] = 0
-		imgdata[y*stride+3*x+2] = 0
+		imgdata[y*stride+3*x+0] = r
+		imgdata[y*stride+3*x+1] = g
+		imgdata[y*stride+3*x+2] = b
 	}
+}
+
+func (a *AppWindow) updateGeneratedSampleImage(sample []float64) {
+	sample = wav.Downmix(sample, a.generatedChannels)
+
+	alloc := a.imgGeneratedSample.GetAllocation()
+	w := alloc.GetWidth()
+	h := alloc.GetHeight()
+
+	imgdata := make([]byte, w*h*3, w*h*3)
+	stride := w * 3
+	for idx, _ := range imgdata {
+		imgdata[idx] = 255
+	}
+
+	l := len(sample)
+	if len(a.referenceSample) > l {
+		l = len(a.referenceSample)
+	}
+	// Draw the reference first, so that the generated sample stays visible.
+	plotSample(imgdata, w, h, a.referenceSample, l, 0xff, 0x80, 0x00)
+	plotSample(imgdata, w, h, sample, l, 0, 0, 0)
+
 	pixbuf, err := gdk.PixbufNewFromData(imgdata, gdk.COLORSPACE_RGB, false, 8, w, h, stride)
 	if err != nil {
 		panic(err)
@@ -420,6 +893,55 @@ func (a *AppWindow) updateGeneratedSampleImage(sample []float64) {
 	a.imgGeneratedSample.SetFromPixbuf(pixbuf)
 }
 
+// formatHz formats a frequency for the unit labels.
+func formatHz(hz float64) string {
+	switch {
+	case hz >= 1000:
+		return fmt.Sprintf("%.2f kHz", hz/1000)
+	case hz >= 100:
+		return fmt.Sprintf("%.0f Hz", hz)
+	}
+	return fmt.Sprintf("%.1f Hz", hz)
+}
+
+// formatDuration formats a duration for the unit labels.
+func formatDuration(d time.Duration) string {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms < 10 {
+		return fmt.Sprintf("%.1f ms", ms)
+	}
+	return fmt.Sprintf("%.0f ms", ms)
+}
+
+// formatPeriod formats how often something happens, where 0 means never.
+func formatPeriod(d time.Duration) string {
+	if d == 0 {
+		return "off"
+	}
+	return formatDuration(d)
+}
+
+// formatSemitones formats an interval for the unit labels.
+func formatSemitones(semitones float64) string {
+	if math.Abs(semitones) < 0.05 {
+		return "0 st"
+	}
+	return fmt.Sprintf("%+.1f st", semitones)
+}
+
+// formatOctaves formats a frequency slide for the unit labels.
+func formatOctaves(octaves float64) string {
+	if math.Abs(octaves) < 0.05 {
+		return "0 oct/s"
+	}
+	return fmt.Sprintf("%+.1f oct/s", octaves)
+}
+
+// generatorOptions returns how sounds are rendered for playing and exporting.
+func (a *AppWindow) generatorOptions() generator.Options {
+	return generator.Options{MaxDuration: generator.DefaultMaxDuration, BandLimited: a.checkBandLimited.GetActive()}
+}
+
 func (a *AppWindow) updateControls() {
 	if a.updating {
 		return
@@ -442,6 +964,7 @@ func (a *AppWindow) updateControls() {
 	a.adFreqDeltaSlide.SetValue(a.generatorConfig.FreqDeltaSlide)
 	a.adjVibratoDepth.SetValue(a.generatorConfig.VibDepth)
 	a.adjVibratoSpeed.SetValue(a.generatorConfig.VibSpeed)
+	a.adjVibratoDelay.SetValue(a.generatorConfig.VibDelay)
 	a.adjArpFreqMult.SetValue(a.generatorConfig.ArpFreqMult)
 	a.adjArpChangeSpeed.SetValue(a.generatorConfig.ArpChangeSpeed)
 	a.adjDutyCycleCycle.SetValue(a.generatorConfig.DutyCycle)
@@ -454,10 +977,36 @@ func (a *AppWindow) updateControls() {
 	a.adjLPResonance.SetValue(a.generatorConfig.LPResonance)
 	a.adjHPCutoffFreq.SetValue(a.generatorConfig.HPCutoffFreq)
 	a.adjHPCutoffSweep.SetValue(a.generatorConfig.HPCutoffSweep)
+	a.adjStereoPan.SetValue(a.generatorConfig.Pan)
+	a.adjStereoSpread.SetValue(a.generatorConfig.StereoSpread)
+	a.adjStereoDetune.SetValue(a.generatorConfig.StereoDetune)
+	a.adjBitCrushDepth.SetValue(a.generatorConfig.BitCrush)
+	a.adjBitCrushSweep.SetValue(a.generatorConfig.BitCrushSweep)
+	a.adjDownsampleFactor.SetValue(a.generatorConfig.Downsample)
+	a.adjDownsampleSweep.SetValue(a.generatorConfig.DownsampleSweep)
+	a.adjCompThreshold.SetValue(a.generatorConfig.CompThreshold)
+	a.adjCompRatio.SetValue(a.generatorConfig.CompRatio)
+	a.adjCompMakeup.SetValue(a.generatorConfig.CompMakeup)
+	for _, u := range a.unitLabels {
+		u.label.SetText(u.text(a.generatorConfig))
+	}
 
-	sample := generator.New(a.generatorConfig).Generate()
-	a.generatedSample = sample
-	a.updateGeneratedSampleImage(sample)
+	layer := a.layer()
+	a.listLayers.SelectRow(a.listLayers.GetRowAtIndex(a.currentLayer))
+	a.btnLayerRemove.SetSensitive(len(a.layers.Layers) > 1)
+	a.adjLayerOffset.SetValue(layer.Offset * 1000)
+	a.adjLayerGain.SetValue(layer.Gain)
+	a.adjLayerPitch.SetValue(layer.Pitch)
+
+	var truncated bool
+	a.generatedSample, a.generatedChannels, truncated = a.layers.Render(a.generatorOptions())
+	a.updateGeneratedSampleImage(a.generatedSample)
+	frames := len(a.generatedSample) / a.generatedChannels
+	duration := fmt.Sprintf("%d ms, %d samples", generator.FramesDuration(frames, generator.DefaultSampleRate).Milliseconds(), frames)
+	if truncated {
+		duration += " (cut off)"
+	}
+	a.labelDuration.SetText(duration)
 
 	a.updating = false
 }
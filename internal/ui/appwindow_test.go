@@ -20,6 +20,7 @@ package ui
 
 import (
 	"testing"
+	"time"
 )
 
 func Test_fixExtensions(t *testing.T) {
@@ -57,3 +58,73 @@ func Test_fixExtensions(t *testing.T) {
 		})
 	}
 }
+
+func Test_filterExtension(t *testing.T) {
+	exts := []string{".json", ".sfxl", ".sfs"}
+	tests := []struct {
+		name   string
+		filter int
+		want   string
+	}{
+		{name: "First filter", filter: 0, want: ".json"},
+		{name: "Last filter", filter: 2, want: ".sfs"},
+		{name: "All files", filter: -1, want: ".json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterExtension(exts, tt.filter); got != tt.want {
+				t.Errorf("filterExtension() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseComboInt(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int
+		wantErr bool
+	}{
+		{text: "48000 Hz", want: 48000},
+		{text: " 8000", want: 8000},
+		{text: "", wantErr: true},
+		{text: "fast", wantErr: true},
+		{text: "-1 Hz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseComboInt(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseComboInt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseComboInt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatUnits(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{got: formatHz(3.528), want: "3.5 Hz"},
+		{got: formatHz(880.4), want: "880 Hz"},
+		{got: formatHz(3531.5), want: "3.53 kHz"},
+		{got: formatDuration(730 * time.Microsecond), want: "0.7 ms"},
+		{got: formatDuration(120 * time.Millisecond), want: "120 ms"},
+		{got: formatPeriod(0), want: "off"},
+		{got: formatPeriod(time.Second), want: "1000 ms"},
+		{got: formatSemitones(12), want: "+12.0 st"},
+		{got: formatSemitones(-0.01), want: "0 st"},
+		{got: formatOctaves(-2.54), want: "-2.5 oct/s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
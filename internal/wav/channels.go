@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+// Deinterleave splits interleaved samples into channels.
+func Deinterleave(data []float64, channels int) [][]float64 {
+	if channels == 1 {
+		return [][]float64{data}
+	}
+	res := make([][]float64, channels)
+	for c := range res {
+		res[c] = make([]float64, len(data)/channels)
+		for i := range res[c] {
+			res[c][i] = data[i*channels+c]
+		}
+	}
+	return res
+}
+
+// Interleave is the inverse of Deinterleave.
+func Interleave(channels [][]float64) []float64 {
+	if len(channels) == 1 {
+		return channels[0]
+	}
+	res := make([]float64, len(channels)*len(channels[0]))
+	for c, data := range channels {
+		for i, s := range data {
+			res[i*len(channels)+c] = s
+		}
+	}
+	return res
+}
+
+// Downmix averages the channels of interleaved samples.
+func Downmix(data []float64, channels int) []float64 {
+	if channels == 1 {
+		return data
+	}
+	res := make([]float64, len(data)/channels)
+	for i := range res {
+		s := 0.0
+		for c := 0; c < channels; c++ {
+			s += data[i*channels+c]
+		}
+		res[i] = s / float64(channels)
+	}
+	return res
+}
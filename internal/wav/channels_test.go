@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterleave(t *testing.T) {
+	data := []float64{1, -1, 2, -2, 3, -3}
+	parts := Deinterleave(data, 2)
+	if want := [][]float64{{1, 2, 3}, {-1, -2, -3}}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("Deinterleave() = %v, want %v", parts, want)
+	}
+	if got := Interleave(parts); !reflect.DeepEqual(got, data) {
+		t.Errorf("Interleave() = %v, want %v", got, data)
+	}
+}
+
+func TestDownmix(t *testing.T) {
+	if got, want := Downmix([]float64{1, 0, -1, -0.5}, 2), []float64{0.5, -0.75}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Downmix() = %v, want %v", got, want)
+	}
+}
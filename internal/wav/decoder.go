@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// Format describes the sample format of a WAV file.
+type Format struct {
+	Channels   int
+	SampleRate int
+	// Bits per sample
+	Bits int
+	// Float is true for IEEE float samples, false for PCM.
+	Float bool
+}
+
+// Decode reads a WAV file from r. It supports PCM with 8, 16 or 24 bits
+// and 32-bit IEEE floats, also wrapped in WAVE_FORMAT_EXTENSIBLE, with any
+// number of channels. The samples are returned interleaved and scaled to
+// [-1, 1] the same way Encode scales them, so that encoding and decoding
+// gives back the original samples up to quantization. Unknown chunks are
+// skipped.
+func Decode(r io.Reader) ([]float64, Format, error) {
+	var format Format
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, format, err
+	}
+	if len(content) < 12 || string(content[0:4]) != "RIFF" || string(content[8:12]) != "WAVE" {
+		return nil, format, fmt.Errorf("not a WAV file")
+	}
+
+	var fmtChunk, dataChunk []byte
+	for pos := 12; pos+8 <= len(content); {
+		id := string(content[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(content[pos+4:]))
+		pos += 8
+		if size > len(content)-pos {
+			// Some writers don't fix up the sizes of the last chunk, so
+			// just take what's there.
+			size = len(content) - pos
+		}
+		switch id {
+		case "fmt ":
+			fmtChunk = content[pos : pos+size]
+		case "data":
+			dataChunk = content[pos : pos+size]
+		}
+		// Chunks are word aligned. The pad byte is missing in some files,
+		// which is fine as long as it's the last chunk.
+		pos += size + size&1
+	}
+	if fmtChunk == nil {
+		return nil, format, fmt.Errorf("no fmt chunk")
+	}
+	if dataChunk == nil {
+		return nil, format, fmt.Errorf("no data chunk")
+	}
+
+	format, err = parseFmtChunk(fmtChunk)
+	if err != nil {
+		return nil, format, err
+	}
+	return decodeSamples(dataChunk, format), format, nil
+}
+
+func parseFmtChunk(chunk []byte) (Format, error) {
+	var format Format
+	if len(chunk) < 16 {
+		return format, fmt.Errorf("fmt chunk too short")
+	}
+	tag := binary.LittleEndian.Uint16(chunk[0:])
+	format.Channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+	format.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+	format.Bits = int(binary.LittleEndian.Uint16(chunk[14:]))
+	if tag == formatExtensible {
+		if len(chunk) < 40 {
+			return format, fmt.Errorf("fmt chunk too short")
+		}
+		// The first two bytes of the sub format GUID are the format tag.
+		tag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+
+	switch {
+	case format.Channels == 0:
+		return format, fmt.Errorf("no channels")
+	case format.SampleRate == 0:
+		return format, fmt.Errorf("unsupported sample rate %d", format.SampleRate)
+	case tag == formatPCM && (format.Bits == 8 || format.Bits == 16 || format.Bits == 24):
+	case tag == formatIEEEFloat && format.Bits == 32:
+		format.Float = true
+	case tag == formatPCM || tag == formatIEEEFloat:
+		return format, fmt.Errorf("unsupported bit depth %d", format.Bits)
+	default:
+		return format, fmt.Errorf("unsupported format 0x%04x", tag)
+	}
+	return format, nil
+}
+
+func decodeSamples(data []byte, format Format) []float64 {
+	bytesPerSample := format.Bits / 8
+	// Drop incomplete frames at the end
+	frameSize := bytesPerSample * format.Channels
+	n := len(data) / frameSize * format.Channels
+
+	scale := float64(int64(1)<<uint(format.Bits-1) - 1)
+	res := make([]float64, n)
+	for i := range res {
+		b := data[i*bytesPerSample:]
+		var q int32
+		switch {
+		case format.Float:
+			res[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			continue
+		case format.Bits == 8:
+			// 8-bit WAVs are unsigned
+			q = int32(b[0]) - 128
+		case format.Bits == 16:
+			q = int32(int16(binary.LittleEndian.Uint16(b)))
+		case format.Bits == 24:
+			q = int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		}
+		// The most negative value is slightly below -1, clip it.
+		res[i] = math.Max(-1, float64(q)/scale)
+	}
+	return res
+}
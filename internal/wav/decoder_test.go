@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// riff assembles a WAV file from chunks. Each chunk is an id followed by
+// its content; odd sized chunks get a pad byte.
+func riff(chunks ...interface{}) []byte {
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for i := 0; i < len(chunks); i += 2 {
+		content := chunks[i+1].([]byte)
+		body.WriteString(chunks[i].(string))
+		binary.Write(&body, binary.LittleEndian, uint32(len(content)))
+		body.Write(content)
+		if len(content)&1 == 1 {
+			body.WriteByte(0)
+		}
+	}
+	var res bytes.Buffer
+	res.WriteString("RIFF")
+	binary.Write(&res, binary.LittleEndian, uint32(body.Len()))
+	res.Write(body.Bytes())
+	return res.Bytes()
+}
+
+func fmtChunk(tag, channels, rate, bits int) []byte {
+	var buf bytes.Buffer
+	bytesPerFrame := channels * bits / 8
+	binary.Write(&buf, binary.LittleEndian, []uint16{uint16(tag), uint16(channels)})
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * bytesPerFrame)})
+	binary.Write(&buf, binary.LittleEndian, []uint16{uint16(bytesPerFrame), uint16(bits)})
+	return buf.Bytes()
+}
+
+func extensibleFmtChunk(subtype, channels, rate, bits int) []byte {
+	var buf bytes.Buffer
+	buf.Write(fmtChunk(formatExtensible, channels, rate, bits))
+	binary.Write(&buf, binary.LittleEndian, []uint16{22, uint16(bits)})
+	binary.Write(&buf, binary.LittleEndian, uint32(0x3))
+	guid := subtypePCM
+	binary.LittleEndian.PutUint16(guid[:], uint16(subtype))
+	buf.Write(guid[:])
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		wav        []byte
+		want       []float64
+		wantFormat Format
+	}{
+		{
+			name:       "8bit",
+			wav:        riff("fmt ", fmtChunk(formatPCM, 1, 8000, 8), "data", []byte{1, 128, 255, 0}),
+			want:       []float64{-1, 0, 1, -1},
+			wantFormat: Format{Channels: 1, SampleRate: 8000, Bits: 8},
+		},
+		{
+			name:       "16bit stereo",
+			wav:        riff("fmt ", fmtChunk(formatPCM, 2, 44100, 16), "data", []byte{0x01, 0x80, 0xff, 0x7f, 0x00, 0x00, 0x00, 0x80}),
+			want:       []float64{-1, 1, 0, -1},
+			wantFormat: Format{Channels: 2, SampleRate: 44100, Bits: 16},
+		},
+		{
+			name:       "24bit extensible",
+			wav:        riff("fmt ", extensibleFmtChunk(formatPCM, 2, 48000, 24), "data", []byte{0x01, 0x00, 0x80, 0xff, 0xff, 0x7f}),
+			want:       []float64{-1, 1},
+			wantFormat: Format{Channels: 2, SampleRate: 48000, Bits: 24},
+		},
+		{
+			name:       "float",
+			wav:        riff("fmt ", fmtChunk(formatIEEEFloat, 1, 22050, 32), "fact", []byte{2, 0, 0, 0}, "data", []byte{0x00, 0x00, 0x00, 0x3f, 0x00, 0x00, 0x80, 0xbf}),
+			want:       []float64{0.5, -1},
+			wantFormat: Format{Channels: 1, SampleRate: 22050, Bits: 32, Float: true},
+		},
+		{
+			name:       "float extensible",
+			wav:        riff("fmt ", extensibleFmtChunk(formatIEEEFloat, 1, 22050, 32), "data", []byte{0x00, 0x00, 0x00, 0x3f}),
+			want:       []float64{0.5},
+			wantFormat: Format{Channels: 1, SampleRate: 22050, Bits: 32, Float: true},
+		},
+		{
+			name:       "unknown chunks",
+			wav:        riff("LIST", []byte("INFOxyz"), "fmt ", fmtChunk(formatPCM, 1, 8000, 8), "junk", []byte{1}, "data", []byte{128, 255}, "cue ", []byte{}),
+			want:       []float64{0, 1},
+			wantFormat: Format{Channels: 1, SampleRate: 8000, Bits: 8},
+		},
+		{
+			name:       "missing pad byte",
+			wav:        riff("fmt ", fmtChunk(formatPCM, 1, 8000, 8), "data", []byte{128, 255, 1})[:8+4+8+16+8+3],
+			want:       []float64{0, 1, -1},
+			wantFormat: Format{Channels: 1, SampleRate: 8000, Bits: 8},
+		},
+		{
+			name:       "truncated data",
+			wav:        riff("fmt ", fmtChunk(formatPCM, 2, 8000, 16), "data", []byte{0xff, 0x7f, 0x01, 0x80, 0xff, 0x7f, 0x01, 0x80})[:8+4+8+16+8+7],
+			want:       []float64{1, -1},
+			wantFormat: Format{Channels: 2, SampleRate: 8000, Bits: 16},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, format, err := Decode(bytes.NewReader(tt.wav))
+			if err != nil {
+				t.Fatalf("Decode() failed: %s", err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %+v, want %+v", format, tt.wantFormat)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("samples = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		wav  []byte
+	}{
+		{name: "empty", wav: nil},
+		{name: "not RIFF", wav: []byte("RIFX\x04\x00\x00\x00WAVE")},
+		{name: "not WAVE", wav: riff()[:8]},
+		{name: "no fmt", wav: riff("data", []byte{0, 0})},
+		{name: "no data", wav: riff("fmt ", fmtChunk(formatPCM, 1, 8000, 8))},
+		{name: "short fmt", wav: riff("fmt ", fmtChunk(formatPCM, 1, 8000, 8)[:14], "data", []byte{0, 0})},
+		{name: "no channels", wav: riff("fmt ", fmtChunk(formatPCM, 0, 8000, 8), "data", []byte{0, 0})},
+		{name: "12bit", wav: riff("fmt ", fmtChunk(formatPCM, 1, 8000, 12), "data", []byte{0, 0})},
+		{name: "64bit float", wav: riff("fmt ", fmtChunk(formatIEEEFloat, 1, 8000, 64), "data", []byte{0, 0})},
+		{name: "ADPCM", wav: riff("fmt ", fmtChunk(2, 1, 8000, 4), "data", []byte{0, 0})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Decode(bytes.NewReader(tt.wav)); err == nil {
+				t.Errorf("Decode() succeeded")
+			}
+		})
+	}
+}
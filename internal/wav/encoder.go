@@ -22,76 +22,175 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 )
 
-func toUint32(val uint32) []byte {
-	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, val)
-	return buf
-}
+// SourceFreq is the default sample rate of the data passed to Encode.
+const SourceFreq = 44100
 
-func toUint16(val uint16) []byte {
-	buf := make([]byte, 2)
-	binary.LittleEndian.PutUint16(buf, val)
-	return buf
+// Options control how samples are encoded.
+type Options struct {
+	Stream
+	// Bits per sample: 8, 16 or 24 for PCM, or 32 for IEEE floats.
+	Bits int
+	// Dither used when quantizing the samples. Ignored for floats.
+	Dither Dither
 }
 
-func Generate(data []float64, bits int, freq int) []byte {
-	// We're cheap and only support 44100 Hz and 22050 Hz, with 8 or 16 bits.
-	if freq != 44100 && freq != 22050 {
-		panic(fmt.Errorf("Unsupported frequency %d", freq))
+// Validate checks whether the options are supported.
+func (o Options) Validate() error {
+	if err := o.Stream.Validate(); err != nil {
+		return err
 	}
-	if bits != 8 && bits != 16 {
-		panic(fmt.Errorf("Unsupported bit depth %d", bits))
+	if o.Bits != 8 && o.Bits != 16 && o.Bits != 24 && o.Bits != 32 {
+		return fmt.Errorf("unsupported bit depth %d", o.Bits)
 	}
+	return nil
+}
 
-	var resampledData []float64
-	if freq == 44100 {
-		// No resampling needed
-		resampledData = data
-	} else {
-		resampledData = make([]float64,len(data)/2);
-		for i := 0; i < len(data)/2; i++ {
-			resampledData[i] = (data[2*i+0] + data[2*i + 1])/2
-		}
+// leWriter writes little-endian values and remembers the first error.
+type leWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *leWriter) write(val interface{}) {
+	if w.err == nil {
+		w.err = binary.Write(w.w, binary.LittleEndian, val)
 	}
+}
+
+// Format tags used in the "fmt " chunk
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xfffe
+)
 
-	var wavData []byte
+// KSDATAFORMAT_SUBTYPE_PCM, the sub format of extensible PCM files.
+var subtypePCM = [16]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
 
-	if bits == 8 {
-		wavData = make([]byte, len(resampledData))
-		for i := 0; i < len(resampledData); i++ {
-			wavData[i] = byte((resampledData[i]+1)/2*255)
+// Channel masks, by number of channels
+var channelMasks = map[int]uint32{
+	1: 0x4, // SPEAKER_FRONT_CENTER
+	2: 0x3, // SPEAKER_FRONT_LEFT | SPEAKER_FRONT_RIGHT
+}
+
+// quantizeInterleaved quantizes each channel on its own, so that the noise
+// shaping doesn't mix the channels.
+func quantizeInterleaved(data []float64, channels, bits int, dither Dither) []int32 {
+	if channels == 1 {
+		return Quantize(data, bits, dither)
+	}
+	res := make([]int32, len(data))
+	for c, channel := range Deinterleave(data, channels) {
+		for i, q := range QuantizeChannel(channel, bits, dither, c) {
+			res[i*channels+c] = q
+		}
+	}
+	return res
+}
+
+func encodeSamples(data []float64, opts Options) []byte {
+	if opts.Bits == 32 {
+		wavData := make([]byte, 4*len(data))
+		for i, s := range data {
+			binary.LittleEndian.PutUint32(wavData[4*i:], math.Float32bits(float32(s)))
 		}
-	} else {
-		wavData = make([]byte, 2*len(resampledData))
-		for i := 0; i < len(resampledData); i++ {
-			val := toUint16(uint16(resampledData[i] * 32767))
-			wavData[2*i+0] = val[0]
-			wavData[2*i+1] = val[1]
+		return wavData
+	}
+
+	quantized := quantizeInterleaved(data, opts.NumChannels(), opts.Bits, opts.Dither)
+	bytesPerSample := opts.Bits / 8
+	wavData := make([]byte, bytesPerSample*len(quantized))
+	for i, q := range quantized {
+		switch opts.Bits {
+		case 8:
+			// 8-bit WAVs are unsigned
+			wavData[i] = byte(q + 128)
+		case 16:
+			binary.LittleEndian.PutUint16(wavData[2*i:], uint16(int16(q)))
+		case 24:
+			wavData[3*i+0] = byte(q)
+			wavData[3*i+1] = byte(q >> 8)
+			wavData[3*i+2] = byte(q >> 16)
 		}
 	}
+	return wavData
+}
+
+// Encode writes data as a WAV file to w. 8- and 16-bit files use plain PCM,
+// 24-bit files WAVE_FORMAT_EXTENSIBLE, and 32-bit files IEEE floats.
+func Encode(w io.Writer, data []float64, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	channels := opts.NumChannels()
+	resampledData := opts.Prepare(data)
+	wavData := encodeSamples(resampledData, opts)
+
+	bytesPerFrame := channels * opts.Bits / 8
+
+	var fmtChunk bytes.Buffer
+	f := &leWriter{w: &fmtChunk}
+	switch opts.Bits {
+	case 24:
+		f.write(uint16(formatExtensible))
+	case 32:
+		f.write(uint16(formatIEEEFloat))
+	default:
+		f.write(uint16(formatPCM))
+	}
+	f.write(uint16(channels))                        // # of channels
+	f.write(uint32(opts.SampleRate))                 // SampleRate
+	f.write(uint32(opts.SampleRate * bytesPerFrame)) // ByteRate
+	f.write(uint16(bytesPerFrame))                   // BlockAlign
+	f.write(uint16(opts.Bits))                       // BitsPerSample
+	switch opts.Bits {
+	case 24:
+		f.write(uint16(22))             // size of the extension
+		f.write(uint16(opts.Bits))      // ValidBitsPerSample
+		f.write(channelMasks[channels]) // ChannelMask
+		f.write(subtypePCM)
+	case 32:
+		f.write(uint16(0)) // size of the extension
+	}
 
+	riffSize := 4 + 8 + fmtChunk.Len() + 8 + len(wavData)
+	// Chunks are word aligned, so odd-sized data is followed by a pad byte
+	// that isn't part of the data chunk.
+	pad := len(wavData) % 2
+	riffSize += pad
+	if opts.Bits == 32 {
+		// Non-PCM files need a "fact" chunk
+		riffSize += 8 + 4
+	}
 
-	buf := bytes.NewBuffer(make([]byte,0,len(data)+100))
+	out := &leWriter{w: w}
 
 	// Write WAV header
-	buf.Write(toUint32(0x46464952)) // "RIFF"
-	buf.Write(toUint32(uint32(4+24+8+len(wavData))))
-	buf.Write(toUint32(0x45564157)) // "WAVE"
-
-	buf.Write(toUint32(0x20746d66)) // "fmt "
-	buf.Write(toUint32(16)  )       // size remaining header
-	buf.Write(toUint16(1)    )      // PCM format
-	buf.Write(toUint16(1)     )     // # of channels
-	buf.Write(toUint32(uint32(freq)        )) // SampleRate
-	buf.Write(toUint32(uint32(freq*1*int(bits/8)))) // ByteRate
-	buf.Write(toUint16(uint16(1*int(bits/8)))) // BlockAlign
-	buf.Write(toUint16(uint16(bits)))
-
-	buf.Write(toUint32(0x61746164)) // "data"
-	buf.Write(toUint32(uint32(len(wavData))))
-	buf.Write(wavData)
-
-	return buf.Bytes()
+	out.write(uint32(0x46464952)) // "RIFF"
+	out.write(uint32(riffSize))
+	out.write(uint32(0x45564157)) // "WAVE"
+
+	out.write(uint32(0x20746d66)) // "fmt "
+	out.write(uint32(fmtChunk.Len()))
+	out.write(fmtChunk.Bytes())
+
+	if opts.Bits == 32 {
+		out.write(uint32(0x74636166)) // "fact"
+		out.write(uint32(4))
+		out.write(uint32(len(resampledData) / channels)) // # of sample frames
+	}
+
+	out.write(uint32(0x61746164)) // "data"
+	out.write(uint32(len(wavData)))
+	out.write(wavData)
+	if pad != 0 {
+		out.write(uint8(0))
+	}
+
+	return out.err
 }
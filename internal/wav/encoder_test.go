@@ -19,6 +19,9 @@
 package wav
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"io/ioutil"
 	"math"
 	"reflect"
@@ -35,7 +38,7 @@ func genSine() []float64 {
 	return buf
 }
 
-func TestGenerate(t *testing.T) {
+func TestEncode(t *testing.T) {
 	type args struct {
 		data func() []float64
 		bits int
@@ -54,7 +57,7 @@ func TestGenerate(t *testing.T) {
 				freq: 44100,
 			},
 			want: []byte{
-				82, 73, 70, 70, 150, 3, 0, 0, 87, 65, 86, 69, 102, 109, 116, 32, 16, 0, 0, 0, 1, 0, 1, 0, 68, 172, 0, 0, 136, 88, 1, 0, 2, 0, 16, 0, 100, 97, 116, 97, 114, 3, 0, 0, 0, 0, 4, 8, 1, 16, 238, 23, 194, 31, 119, 39, 4, 47, 97, 54, 136, 61, 113, 68, 22, 75, 110, 81, 117, 87, 36, 93, 117, 98, 99, 103, 233, 107, 3, 112, 172, 115, 225, 118, 158, 121, 225, 123, 167, 125, 238, 126, 183, 127, 254, 127, 197, 127, 12, 127, 210, 125, 26, 124, 230, 121, 54, 119, 15, 116, 115, 112, 102, 108, 236, 103, 10, 99, 196, 93, 31, 88, 34, 82, 210, 75, 54, 69, 85, 62, 52, 55, 221, 47, 85, 40, 164, 32, 211, 24, 233, 16, 237, 8, 233, 0, 229, 248, 231, 240, 248, 232, 32, 225, 103, 217, 214, 209, 114, 202, 69, 195, 84, 188, 168, 181, 70, 175, 54, 169, 125, 163, 33, 158, 39, 153, 149, 148, 111, 144, 185, 140, 119, 137, 172, 134, 91, 132, 135, 130, 48, 129, 90, 128, 3, 128, 46, 128, 217, 128, 4, 130, 173, 131, 212, 133, 117, 136, 143, 139, 30, 143, 30, 147, 140, 151, 99, 156, 158, 161, 56, 167, 44, 173, 114, 179, 6, 186, 224, 192, 249, 199, 75, 207, 206, 214, 122, 222, 72, 230, 48, 238, 42, 246, 46, 254, 50, 6, 49, 14, 34, 22, 253, 29, 186, 37, 80, 45, 185, 52, 237, 59, 229, 66, 154, 73, 4, 80, 30, 86, 225, 91, 72, 97, 77, 102, 236, 106, 30, 111, 225, 114, 49, 118, 9, 121, 104, 123, 75, 125, 175, 126, 148, 127, 249, 127, 222, 127, 65, 127, 37, 126, 137, 124, 113, 122, 221, 119, 209, 116, 79, 113, 92, 109, 250, 104, 47, 100, 255, 94, 111, 89, 134, 83, 72, 77, 189, 70, 235, 63, 216, 56, 140, 49, 15, 42, 103, 34, 156, 26, 183, 18, 191, 10, 188, 2, 183, 250, 183, 242, 196, 234, 230, 226, 38, 219, 138, 211, 28, 204, 225, 196, 226, 189, 38, 183, 179, 176, 143, 170, 194, 164, 80, 159, 63, 154, 149, 149, 86, 145, 134, 141, 42, 138, 68, 135, 215, 132, 230, 130, 115, 129, 127, 128, 12, 128, 25, 128, 167, 128, 181, 129, 65, 131, 76, 133, 210, 135, 208, 138, 69, 142, 44, 146, 129, 150, 65, 155, 101, 160, 234, 165, 202, 171, 254, 177, 129, 184, 75, 191, 87, 198, 157, 205, 21, 213, 185, 220, 128, 228, 98, 236, 89, 244, 91, 252, 96, 4, 97, 12, 86, 20, 55, 28, 251, 35, 155, 43, 15, 51, 79, 58, 85, 65, 26, 72, 150, 78, 194, 84, 154, 90, 22, 96, 50, 101, 232, 105, 52, 110, 16, 114, 122, 117, 110, 120, 233, 122, 232, 124, 105, 126, 108, 127, 238, 127, 239, 127, 112, 127, 113, 126, 242, 124, 246, 122, 126, 120, 141, 117, 38, 114, 75, 110, 2, 106, 79, 101, 53, 96, 187, 90, 229, 84, 186, 78, 64, 72, 126, 65, 121, 58, 57, 51, 198, 43, 39, 36, 100, 28, 132, 20, 144, 12, 142, 4, 138, 252, 135, 244, 145, 236, 173, 228, 230, 220, 65, 213, 200, 205, 129, 198, 116, 191, 168, 184, 35, 178, 237, 171, 11, 166, 132, 160, 93, 155, 156, 150, 68, 146, 90, 142, 227, 138, 226, 135, 89, 133, 76, 131, 188, 129, 171, 128, 27, 128, 11, 128, 123, 128, 108, 129, 220, 130, 202, 132, 52, 135, 24, 138, 114, 141, 63, 145, 123, 149, 35, 154, 49, 159, 161, 164, 108, 170, 142, 176, 255, 182, 186, 189, 184, 196, 241, 203, 94, 211, 249, 218, 184, 226, 150, 234, 136, 242, 136, 250, 141, 2, 144, 10, 137, 18, 110, 26, 58, 34, 227, 41, 97, 49, 174, 56, 194, 63, 150, 70, 35, 77, 98, 83, 78, 89, 224, 94, 18, 100, 223, 104, 67, 109, 58, 113, 190, 116, 205, 119, 99, 122, 127, 124, 29, 126, 60, 127, 219, 127, 250, 127, 152, 127, 182, 126, 84, 125, 116, 123, 24, 121, 66, 118, 246, 114, 53, 111, 5, 107, 105, 102, 103, 97, 2, 92, 65, 86, 41, 80, 192, 73, 13, 67, 23, 60, 228, 52, 124, 45, 230, 37, 43, 30, 80, 22, 96, 14, 97, 6, 92, 254, 89, 246, 94, 238, 118, 230, 167, 222, 250, 214, 118, 207, 35, 200, 8, 193, 45, 186, 152, 179, 79, 173, 90, 167, 190, 161, 128, 156, 167, 151, 55, 147, 52, 143, 162, 139, 134, 136, 226, 133, 184, 131, 12, 130, 222, 128, 48, 128, 3, 128, 86, 128, 42, 129, 125, 130, 79, 132, 157, 134, 101, 137, 164, 140, 88, 144, 123, 148, 11, 153, 3, 158, 92, 163, 20, 169, 34, 175, 130, 181, 45, 188, 28, 195, 72, 202, 170, 209, 59, 217, 243, 224, 202, 232, 184, 240, 182, 248, 186, 0, 191, 8, 186, 16, 165, 24, 119, 32, 40, 40, 177, 47, 10, 55, 44, 62, 15, 69, 173, 75, 254, 81, 253, 87, 164, 93, 236, 98, 209, 103, 77, 108, 93, 112, 252, 115, 37, 119, 215, 121, 15, 124, 202, 125, 6, 127, 194, 127, 254, 127, 186, 127, 244, 126, 176, 125, 236, 123, 172, 121, 242, 118, 192, 115, 26, 112, 3, 108, 127, 103, 147, 98, 68, 93, 151, 87, 146, 81,
+				82, 73, 70, 70, 150, 3, 0, 0, 87, 65, 86, 69, 102, 109, 116, 32, 16, 0, 0, 0, 1, 0, 1, 0, 68, 172, 0, 0, 136, 88, 1, 0, 2, 0, 16, 0, 100, 97, 116, 97, 114, 3, 0, 0, 0, 0, 5, 8, 2, 16, 238, 23, 195, 31, 119, 39, 4, 47, 98, 54, 137, 61, 114, 68, 22, 75, 111, 81, 118, 87, 37, 93, 118, 98, 100, 103, 234, 107, 4, 112, 173, 115, 225, 118, 158, 121, 225, 123, 167, 125, 239, 126, 183, 127, 255, 127, 198, 127, 12, 127, 211, 125, 27, 124, 230, 121, 55, 119, 16, 116, 116, 112, 103, 108, 237, 103, 10, 99, 196, 93, 32, 88, 34, 82, 211, 75, 55, 69, 85, 62, 53, 55, 221, 47, 85, 40, 165, 32, 211, 24, 233, 16, 238, 8, 233, 0, 228, 248, 230, 240, 247, 232, 32, 225, 103, 217, 213, 209, 114, 202, 68, 195, 84, 188, 167, 181, 70, 175, 53, 169, 124, 163, 32, 158, 38, 153, 148, 148, 110, 144, 184, 140, 118, 137, 171, 134, 90, 132, 134, 130, 48, 129, 89, 128, 3, 128, 45, 128, 216, 128, 3, 130, 173, 131, 211, 133, 117, 136, 143, 139, 29, 143, 30, 147, 140, 151, 99, 156, 158, 161, 56, 167, 43, 173, 114, 179, 5, 186, 223, 192, 249, 199, 75, 207, 206, 214, 122, 222, 72, 230, 48, 238, 41, 246, 45, 254, 51, 6, 50, 14, 35, 22, 254, 29, 186, 37, 81, 45, 186, 52, 238, 59, 230, 66, 154, 73, 5, 80, 31, 86, 226, 91, 73, 97, 78, 102, 236, 106, 31, 111, 226, 114, 49, 118, 10, 121, 104, 123, 75, 125, 176, 126, 149, 127, 250, 127, 222, 127, 66, 127, 37, 126, 138, 124, 113, 122, 222, 119, 210, 116, 80, 113, 92, 109, 251, 104, 47, 100, 255, 94, 112, 89, 134, 83, 73, 77, 190, 70, 235, 63, 217, 56, 141, 49, 15, 42, 103, 34, 157, 26, 183, 18, 191, 10, 188, 2, 183, 250, 182, 242, 195, 234, 229, 226, 37, 219, 138, 211, 27, 204, 225, 196, 226, 189, 37, 183, 178, 176, 143, 170, 193, 164, 79, 159, 63, 154, 149, 149, 86, 145, 134, 141, 41, 138, 67, 135, 214, 132, 230, 130, 114, 129, 127, 128, 11, 128, 24, 128, 166, 128, 180, 129, 65, 131, 75, 133, 209, 135, 208, 138, 68, 142, 43, 146, 129, 150, 64, 155, 101, 160, 234, 165, 201, 171, 254, 177, 128, 184, 75, 191, 87, 198, 156, 205, 21, 213, 184, 220, 127, 228, 98, 236, 88, 244, 90, 252, 96, 4, 98, 12, 87, 20, 55, 28, 251, 35, 155, 43, 15, 51, 80, 58, 86, 65, 26, 72, 150, 78, 195, 84, 155, 90, 23, 96, 51, 101, 233, 105, 52, 110, 17, 114, 123, 117, 111, 120, 233, 122, 232, 124, 106, 126, 108, 127, 238, 127, 240, 127, 112, 127, 113, 126, 242, 124, 246, 122, 126, 120, 141, 117, 38, 114, 76, 110, 3, 106, 79, 101, 54, 96, 187, 90, 230, 84, 187, 78, 65, 72, 126, 65, 121, 58, 58, 51, 199, 43, 40, 36, 101, 28, 133, 20, 144, 12, 143, 4, 137, 252, 135, 244, 144, 236, 173, 228, 229, 220, 65, 213, 199, 205, 128, 198, 115, 191, 167, 184, 35, 178, 237, 171, 11, 166, 132, 160, 93, 155, 155, 150, 67, 146, 90, 142, 227, 138, 225, 135, 89, 133, 75, 131, 188, 129, 171, 128, 26, 128, 10, 128, 123, 128, 108, 129, 220, 130, 202, 132, 52, 135, 23, 138, 113, 141, 62, 145, 123, 149, 35, 154, 49, 159, 160, 164, 108, 170, 141, 176, 255, 182, 186, 189, 183, 196, 240, 203, 94, 211, 248, 218, 184, 226, 149, 234, 136, 242, 136, 250, 142, 2, 145, 10, 137, 18, 111, 26, 58, 34, 227, 41, 98, 49, 175, 56, 195, 63, 151, 70, 36, 77, 99, 83, 78, 89, 224, 94, 18, 100, 224, 104, 68, 109, 58, 113, 190, 116, 205, 119, 100, 122, 127, 124, 29, 126, 61, 127, 220, 127, 251, 127, 153, 127, 182, 126, 85, 125, 117, 123, 25, 121, 67, 118, 246, 114, 54, 111, 6, 107, 106, 102, 103, 97, 2, 92, 65, 86, 41, 80, 192, 73, 13, 67, 23, 60, 228, 52, 125, 45, 231, 37, 43, 30, 81, 22, 96, 14, 97, 6, 92, 254, 88, 246, 94, 238, 118, 230, 167, 222, 250, 214, 118, 207, 35, 200, 8, 193, 44, 186, 151, 179, 79, 173, 89, 167, 189, 161, 128, 156, 167, 151, 54, 147, 51, 143, 162, 139, 134, 136, 225, 133, 184, 131, 11, 130, 222, 128, 48, 128, 2, 128, 86, 128, 42, 129, 125, 130, 78, 132, 156, 134, 100, 137, 164, 140, 87, 144, 123, 148, 11, 153, 2, 158, 92, 163, 19, 169, 34, 175, 129, 181, 44, 188, 27, 195, 71, 202, 170, 209, 58, 217, 242, 224, 201, 232, 184, 240, 182, 248, 187, 0, 191, 8, 187, 16, 166, 24, 119, 32, 41, 40, 178, 47, 11, 55, 44, 62, 15, 69, 173, 75, 255, 81, 254, 87, 164, 93, 237, 98, 209, 103, 78, 108, 94, 112, 252, 115, 38, 119, 216, 121, 16, 124, 202, 125, 6, 127, 195, 127, 255, 127, 186, 127, 245, 126, 176, 125, 237, 123, 173, 121, 242, 118, 193, 115, 26, 112, 3, 108, 127, 103, 148, 98, 69, 93, 152, 87, 147, 81,
 			},
 		},
 		{
@@ -65,7 +68,7 @@ func TestGenerate(t *testing.T) {
 				freq: 44100,
 			},
 			want: []byte{
-				82,73,70,70,221,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,68,172,0,0,68,172,0,0,1,0,8,0,100,97,116,97,185,1,0,0,127,135,143,151,159,166,174,181,188,195,202,208,214,220,225,230,234,239,242,245,248,250,252,253,254,254,254,254,252,251,248,246,243,239,235,231,226,220,215,209,203,196,189,182,175,167,160,152,144,136,128,120,112,104,96,89,81,74,67,60,53,47,41,35,30,25,20,16,12,9,6,4,2,1,0,0,0,0,2,3,5,8,11,15,19,23,28,33,39,44,51,57,64,71,78,86,94,101,109,117,125,133,141,149,157,165,172,180,187,194,200,207,213,219,224,229,234,238,241,245,248,250,252,253,254,254,254,254,253,251,249,246,243,240,236,232,227,222,216,210,204,197,191,184,176,169,161,154,146,138,130,122,114,106,98,90,83,75,68,61,54,48,42,36,31,26,21,17,13,10,7,4,2,1,0,0,0,0,1,3,5,7,10,14,18,22,27,32,37,43,49,56,63,70,77,84,92,100,107,115,123,131,139,147,155,163,170,178,185,192,199,205,211,217,223,228,232,237,241,244,247,249,251,253,254,254,254,254,253,251,249,247,244,241,237,233,228,223,217,212,205,199,192,185,178,171,163,155,147,140,132,124,116,108,100,92,84,77,70,63,56,49,43,37,32,27,22,18,14,10,7,5,3,1,0,0,0,0,1,2,4,7,10,13,17,21,26,31,36,42,48,54,61,68,75,83,90,98,106,114,122,130,138,145,153,161,169,176,183,191,197,204,210,216,222,227,231,236,240,243,246,249,251,253,254,254,254,254,253,252,250,248,245,242,238,234,229,224,219,213,207,200,194,187,180,172,165,157,149,141,133,125,117,109,102,94,86,79,71,64,57,51,45,39,33,28,23,19,15,11,8,5,3,2,0,0,0,0,1,2,4,6,9,12,16,20,24,29,35,40,46,53,59,66,73,81,88,96,104,112,120,128,136,144,152,159,167,175,182,189,196,202,209,215,220,226,230,235,239,243,246,248,251,252,254,254,254,254,253,252,250,248,245,242,239,235,230,225,220,214,208,
+				82,73,70,70,222,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,68,172,0,0,68,172,0,0,1,0,8,0,100,97,116,97,185,1,0,0,128,136,144,152,160,167,175,182,189,196,203,209,215,220,226,231,235,239,243,246,249,251,253,254,255,255,255,254,253,251,249,246,243,240,236,231,226,221,215,209,203,197,190,183,175,168,160,153,145,137,129,121,113,105,97,90,82,75,68,61,54,48,42,36,31,26,21,17,14,10,8,5,4,2,1,1,1,2,3,5,7,9,12,16,20,24,29,34,40,46,52,59,65,72,80,87,95,102,110,118,126,134,142,150,158,165,173,180,187,194,201,207,213,219,225,230,234,238,242,245,248,250,252,254,255,255,255,254,253,252,249,247,244,240,237,232,227,222,217,211,205,198,191,184,177,170,162,154,147,139,131,123,115,107,99,91,84,77,69,62,56,49,43,37,32,27,22,18,14,11,8,6,4,2,1,1,1,2,3,4,6,9,12,15,19,23,28,33,39,44,51,57,64,71,78,85,93,101,109,116,124,132,140,148,156,164,171,179,186,193,200,206,212,218,223,228,233,237,241,245,247,250,252,253,254,255,255,254,253,252,250,248,245,241,237,233,229,223,218,212,206,200,193,186,179,171,164,156,148,140,133,125,117,109,101,93,86,78,71,64,57,51,45,39,33,28,23,19,15,12,9,6,4,3,2,1,1,1,2,4,6,8,11,14,18,22,27,32,37,43,49,56,62,69,76,84,91,99,107,115,123,131,138,146,154,162,170,177,184,191,198,205,211,217,222,227,232,236,240,244,247,249,252,253,254,255,255,255,254,252,250,248,245,242,238,234,230,225,219,214,208,201,195,188,180,173,166,158,150,142,134,126,118,111,103,95,87,80,73,66,59,52,46,40,34,29,24,20,16,13,9,7,5,3,2,1,1,1,2,3,5,8,10,14,17,21,26,31,36,42,48,54,61,68,75,82,90,97,105,113,121,129,137,145,152,160,168,175,183,190,197,203,209,215,221,226,231,235,239,243,246,249,251,253,254,255,255,255,254,253,251,249,246,243,239,235,231,226,221,215,209,0, // pad byte
 			},
 		},
 		{
@@ -76,7 +79,7 @@ func TestGenerate(t *testing.T) {
 				freq: 22050,
 			},
 			want: []byte{
-				82,73,70,70,220,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,34,86,0,0,68,172,0,0,2,0,16,0,100,97,116,97,184,1,0,0,2,4,247,19,157,35,179,50,253,64,66,78,77,90,236,100,246,109,70,117,191,122,75,126,218,127,104,127,246,124,142,120,65,114,41,106,103,96,32,85,132,72,197,58,25,44,187,28,235,12,231,252,239,236,68,221,36,206,204,191,119,178,89,166,164,155,130,146,24,139,131,133,219,129,47,128,131,128,216,130,37,135,86,141,85,149,1,159,50,170,188,182,109,196,13,211,97,226,45,242,47,2,42,18,219,33,5,49,105,63,207,76,0,89,203,99,5,109,137,116,56,122,253,125,199,127,143,127,87,125,39,121,16,115,43,107,151,97,123,86,3,74,97,60,205,45,129,30,187,14,186,254,189,238,6,223,211,207,98,193,236,179,168,167,200,156,118,147,216,139,13,134,44,130,69,128,96,128,123,130,143,134,139,140,86,148,211,157,218,168,63,181,209,194,89,209,156,224,94,240,93,0,92,16,25,32,85,47,210,61,88,75,174,87,164,98,14,108,197,115,171,121,169,125,173,127,176,127,177,125,186,121,217,115,39,108,194,98,208,87,125,75,251,61,128,47,70,32,138,16,139,0,140,240,201,224,132,209,250,194,101,181,252,168,241,157,112,148,159,140,157,134,132,130,99,128,67,128,36,130,255,133,197,139,93,147,170,156,135,167,199,179,57,193,168,207,217,222,143,238,139,254,140,14,84,30,162,45,56,60,221,73,88,86,121,97,17,107,252,114,24,121,78,125,140,127,201,127,5,126,70,122,156,116,29,109,232,99,33,89,244,76,146,63,48,49,8,34,88,18,94,2,91,242,143,226,56,211,150,196,226,182,85,170,31,159,111,149,107,141,52,135,226,130,135,128,45,128,212,129,118,133,5,139,106,146,135,155,56,166,82,178,164,191,249,205,23,221,193,236,185,252,188,12,142,28,237,43,155,58,94,72,254,84,72,96,15,106,44,114,126,120,236,124,100,127,220,127,82,126,204,122,89,117,14,110,9,101,110,90,
+				82,73,70,70,220,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,34,86,0,0,68,172,0,0,2,0,16,0,100,97,116,97,184,1,0,0,99,1,184,15,237,31,229,46,162,61,2,75,133,87,106,98,242,107,167,115,162,121,165,125,184,127,197,127,211,125,230,121,16,116,103,108,10,99,31,88,211,75,85,62,221,47,165,32,233,16,233,0,230,240,32,225,213,209,68,195,167,181,53,169,32,158,148,148,184,140,171,134,134,130,89,128,45,128,3,130,211,133,143,139,30,147,99,156,56,167,114,179,223,192,75,207,122,222,48,238,45,254,50,14,254,29,81,45,238,59,154,73,30,86,73,97,236,106,226,114,9,121,75,125,149,127,222,127,37,126,113,122,209,116,92,109,47,100,112,89,73,77,235,63,141,49,103,34,183,18,188,2,182,242,229,226,138,211,225,196,37,183,143,170,80,159,149,149,134,141,67,135,230,130,127,128,25,128,180,129,75,133,208,138,43,146,64,155,234,165,254,177,75,191,156,205,184,220,98,236,90,252,98,12,55,28,155,43,80,58,26,72,195,84,23,96,233,105,17,114,110,120,232,124,108,127,239,127,113,126,246,122,141,117,76,110,79,101,187,90,187,78,126,65,58,51,40,36,133,20,143,4,135,244,173,228,65,213,128,198,167,184,237,171,132,160,155,150,90,142,225,135,76,131,171,128,10,128,108,129,202,132,23,138,62,145,35,154,161,164,142,176,186,189,241,203,248,218,149,234,136,250,145,10,111,26,227,41,175,56,151,70,99,83,224,94,224,104,58,113,205,119,127,124,60,127,250,127,182,126,117,123,67,118,54,111,106,102,2,92,41,80,13,67,228,52,231,37,81,22,97,6,88,246,118,230,250,214,35,200,45,186,79,173,189,161,167,151,52,143,134,136,184,131,222,128,3,128,42,129,79,132,101,137,87,144,11,153,92,163,34,175,44,188,71,202,58,217,201,232,182,248,191,8,165,24,41,40,11,55,15,69,255,81,164,93,206,103,104,112,14,119,61,124,183,126,255,127,47,126,16,125,79,117,118,114,246,99,111,99,
 			},
 		},
 		{
@@ -87,14 +90,324 @@ func TestGenerate(t *testing.T) {
 				freq: 22050,
 			},
 			want: []byte{
-				82,73,70,70,0,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,34,86,0,0,34,86,0,0,1,0,8,0,100,97,116,97,220,0,0,0,131,147,162,178,192,205,217,228,237,244,249,253,254,254,251,247,241,233,223,212,199,186,171,156,140,124,108,92,77,63,50,38,27,18,11,5,1,0,0,2,7,13,21,30,42,54,68,82,97,113,129,145,161,176,190,204,216,226,236,243,249,253,254,254,252,248,242,234,224,213,201,187,173,157,142,126,110,94,79,65,51,39,28,19,11,6,2,0,0,2,6,12,20,29,40,53,66,81,96,111,127,143,159,174,189,202,214,225,235,242,248,252,254,254,252,248,242,235,225,214,202,189,174,159,143,128,112,96,81,66,53,40,29,20,12,6,2,0,0,2,5,11,19,28,39,51,64,79,94,110,126,141,157,172,187,201,213,224,234,242,248,252,254,254,253,249,243,236,227,216,204,190,176,161,145,129,113,98,82,68,54,42,30,21,13,7,2,0,0,1,5,10,18,27,38,50,63,77,92,108,124,140,155,171,185,199,212,223,233,241,247,251,254,254,253,249,244,237,228,217,			},
+				82,73,70,70,0,1,0,0,87,65,86,69,102,109,116,32,16,0,0,0,1,0,1,0,34,86,0,0,34,86,0,0,1,0,8,0,100,97,116,97,220,0,0,0,129,144,160,175,189,202,215,226,235,243,249,253,255,255,253,249,243,236,226,215,203,190,175,160,145,129,113,97,82,68,54,42,31,21,14,8,4,1,1,3,7,12,20,29,40,52,65,80,95,110,126,142,158,173,187,201,213,225,234,242,248,252,255,255,253,249,244,237,227,217,205,191,177,162,147,131,115,99,84,69,56,43,32,22,14,8,4,1,1,3,6,12,19,28,39,51,64,78,93,109,124,140,156,171,186,200,212,223,233,241,247,252,254,255,253,250,245,237,229,218,206,193,179,164,148,133,117,101,86,71,57,45,33,23,15,9,4,2,1,2,6,11,18,27,37,49,62,76,91,107,123,138,154,170,184,198,211,222,232,240,247,252,254,255,254,250,245,238,230,219,208,195,180,166,150,134,118,103,87,73,59,46,34,24,16,9,5,2,1,2,5,10,17,26,36,48,61,75,90,105,121,137,152,168,183,197,209,221,231,240,246,251,254,255,253,252,244,242,227,227,			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := Generate(tt.args.data(), tt.args.bits, tt.args.freq); !reflect.DeepEqual(got, tt.want) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tt.args.data(), Options{Stream: Stream{SampleRate: tt.args.freq}, Bits: tt.args.bits}); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			if got := buf.Bytes(); !reflect.DeepEqual(got, tt.want) {
 				ioutil.WriteFile("/tmp/"+tt.name+".wav", got, 0644)
-				t.Errorf("Generate() = %v, want %v", got, tt.want)
+				t.Errorf("Encode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Unsupported bit depth", opts: Options{Stream: Stream{SampleRate: 44100}, Bits: 12}},
+		{name: "Zero sample rate", opts: Options{Stream: Stream{SampleRate: 0}, Bits: 16}},
+		{name: "Negative sample rate", opts: Options{Stream: Stream{SampleRate: -44100}, Bits: 16}},
+		{name: "Too many channels", opts: Options{Stream: Stream{SampleRate: 44100, Channels: 3}, Bits: 16}},
+		{name: "Negative source rate", opts: Options{Stream: Stream{SampleRate: 44100, SourceRate: -48000}, Bits: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(), tt.opts); err == nil {
+				t.Errorf("Encode() succeeded with %+v", tt.opts)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Encode() wrote %d bytes", buf.Len())
+			}
+		})
+	}
+
+	if err := Encode(failingWriter{}, genSine(), Options{Stream: Stream{SampleRate: 44100}, Bits: 16}); err == nil {
+		t.Errorf("Encode() ignored write error")
+	}
+}
+
+// chunk returns the content of the first chunk with the given id.
+func chunk(t *testing.T, wav []byte, id string) []byte {
+	if string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		t.Fatalf("not a WAV file")
+	}
+	if got := int(binary.LittleEndian.Uint32(wav[4:])); got != len(wav)-8 {
+		t.Errorf("RIFF size = %d, want %d", got, len(wav)-8)
+	}
+	for pos := 12; pos+8 <= len(wav); {
+		l := int(binary.LittleEndian.Uint32(wav[pos+4:]))
+		if string(wav[pos:pos+4]) == id {
+			return wav[pos+8 : pos+8+l]
+		}
+		pos += 8 + l + l%2
+	}
+	t.Fatalf("no %q chunk", id)
+	return nil
+}
+
+func TestEncodePadsOddData(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     int
+		channels int
+		samples  int
+	}{
+		{name: "8bit", bits: 8, samples: 3},
+		{name: "24bit", bits: 24, samples: 3},
+		{name: "24bit stereo", bits: 24, channels: 2, samples: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			data := make([]float64, tt.samples)
+			if err := Encode(&buf, data, Options{Stream: Stream{SampleRate: SourceFreq, Channels: tt.channels}, Bits: tt.bits}); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			got := buf.Bytes()
+			wantData := tt.samples * tt.bits / 8
+			if l := len(chunk(t, got, "data")); l != wantData {
+				t.Errorf("data chunk has %d bytes, want %d", l, wantData)
+			}
+			if len(got)%2 != 0 || got[len(got)-1] != 0 {
+				t.Errorf("file of %d bytes doesn't end with a pad byte", len(got))
+			}
+		})
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     int
+		channels int
+		wantFmt  []byte
+		wantFact []byte
+	}{
+		{
+			name: "24bit",
+			bits: 24,
+			wantFmt: []byte{
+				0xfe, 0xff, 1, 0, 0x80, 0xbb, 0, 0, 0x80, 0x32, 0x02, 0, 3, 0, 24, 0,
+				22, 0, 24, 0, 4, 0, 0, 0,
+				0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71,
+			},
+		},
+		{
+			name:     "24bit stereo",
+			bits:     24,
+			channels: 2,
+			wantFmt: []byte{
+				0xfe, 0xff, 2, 0, 0x80, 0xbb, 0, 0, 0x00, 0x65, 0x04, 0, 6, 0, 24, 0,
+				22, 0, 24, 0, 3, 0, 0, 0,
+				0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71,
+			},
+		},
+		{
+			name:     "32bit float",
+			bits:     32,
+			wantFmt:  []byte{3, 0, 1, 0, 0x80, 0xbb, 0, 0, 0x00, 0xee, 0x02, 0, 4, 0, 32, 0, 0, 0},
+			wantFact: []byte{224, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(), Options{Stream: Stream{SampleRate: 48000, Channels: tt.channels}, Bits: tt.bits}); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			if got := chunk(t, buf.Bytes(), "fmt "); !reflect.DeepEqual(got, tt.wantFmt) {
+				t.Errorf("fmt = %v, want %v", got, tt.wantFmt)
+			}
+			if tt.wantFact != nil {
+				if got := chunk(t, buf.Bytes(), "fact"); !reflect.DeepEqual(got, tt.wantFact) {
+					t.Errorf("fact = %v, want %v", got, tt.wantFact)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeSampleValues(t *testing.T) {
+	data := []float64{-1, 0, 1, -2, 2}
+	tests := []struct {
+		name string
+		bits int
+		want []byte
+	}{
+		{name: "8bit", bits: 8, want: []byte{1, 128, 255, 1, 255}},
+		{name: "16bit", bits: 16, want: []byte{0x01, 0x80, 0x00, 0x00, 0xff, 0x7f, 0x01, 0x80, 0xff, 0x7f}},
+		{name: "24bit", bits: 24, want: []byte{0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0x7f, 0x01, 0x00, 0x80, 0xff, 0xff, 0x7f}},
+		{name: "32bit float", bits: 32, want: []byte{
+			0x00, 0x00, 0x80, 0xbf, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x3f, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00, 0x40,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, data, Options{Stream: Stream{SampleRate: SourceFreq}, Bits: tt.bits}); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			if got := chunk(t, buf.Bytes(), "data"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("samples = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeSourceRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		sourceRate int
+		wantLen    int
+	}{
+		{name: "Default source rate", sampleRate: 44100, sourceRate: 0, wantLen: 441},
+		{name: "Same rate", sampleRate: 48000, sourceRate: 48000, wantLen: 441},
+		{name: "48k to 24k", sampleRate: 24000, sourceRate: 48000, wantLen: 220},
+		{name: "22.05k to 44.1k", sampleRate: 44100, sourceRate: 22050, wantLen: 882},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, genSine(), Options{Stream: Stream{SampleRate: tt.sampleRate, SourceRate: tt.sourceRate}, Bits: 16}); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			if got := len(chunk(t, buf.Bytes(), "data")) / 2; got != tt.wantLen {
+				t.Errorf("Encode() wrote %d samples, want %d", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestQuantizeRounds(t *testing.T) {
+	got := Quantize([]float64{0.4 / 127, 0.6 / 127, -0.4 / 127, -0.6 / 127}, 8, DitherNone)
+	if want := []int32{0, 1, 0, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Quantize() = %v, want %v", got, want)
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	// A constant signal of 0.3 LSB; without dither, it's lost completely.
+	data := make([]float64, 10000)
+	for i := range data {
+		data[i] = 0.3 / 127
+	}
+
+	for _, d := range []Dither{DitherTPDF, DitherShaped} {
+		q := Quantize(data, 8, d)
+		sum := 0.0
+		for _, v := range q {
+			if v < -2 || v > 2 {
+				t.Fatalf("dither %d: sample %d is too large", d, v)
+			}
+			sum += float64(v)
+		}
+		if mean := sum / float64(len(q)); math.Abs(mean-0.3) > 0.05 {
+			t.Errorf("dither %d: mean = %v, want 0.3", d, mean)
+		}
+		if !reflect.DeepEqual(q, Quantize(data, 8, d)) {
+			t.Errorf("dither %d is not reproducible", d)
+		}
+	}
+
+	// With noise shaping, the error is e[n] - e[n-1], so it doesn't
+	// accumulate at low frequencies.
+	q := Quantize(data, 8, DitherShaped)
+	errSum := 0.0
+	for i, v := range q {
+		errSum += float64(v) - data[i]*127
+	}
+	if math.Abs(errSum) > 3 {
+		t.Errorf("accumulated shaped error = %v", errSum)
+	}
+}
+
+func TestQuantizeChannelDither(t *testing.T) {
+	data := make([]float64, 1000)
+	left := QuantizeChannel(data, 8, DitherTPDF, 0)
+	right := QuantizeChannel(data, 8, DitherTPDF, 1)
+	if !reflect.DeepEqual(left, Quantize(data, 8, DitherTPDF)) {
+		t.Errorf("channel 0 differs from Quantize()")
+	}
+	same := 0
+	for i := range left {
+		if left[i] == right[i] {
+			same++
+		}
+	}
+	// Independent TPDF noise of 1 LSB rounds to the same value in about
+	// half of the samples.
+	if same > len(data)*3/4 {
+		t.Errorf("%d of %d samples have the same dither on both channels", same, len(data))
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		maxDiff float64
+	}{
+		{name: "8bit", opts: Options{Stream: Stream{SampleRate: 44100}, Bits: 8}, maxDiff: 0.5 / 127},
+		{name: "16bit", opts: Options{Stream: Stream{SampleRate: 44100}, Bits: 16}, maxDiff: 0.5 / 32767},
+		{name: "24bit", opts: Options{Stream: Stream{SampleRate: 44100}, Bits: 24}, maxDiff: 0.5 / 8388607},
+		{name: "32bit float", opts: Options{Stream: Stream{SampleRate: 44100}, Bits: 32}, maxDiff: 1e-7},
+		{name: "16bit 48kHz", opts: Options{Stream: Stream{SampleRate: 48000}, Bits: 16}, maxDiff: 0.5 / 32767},
+		{name: "16bit stereo", opts: Options{Stream: Stream{SampleRate: 44100, Channels: 2}, Bits: 16}, maxDiff: 0.5 / 32767},
+		{name: "24bit stereo 48kHz", opts: Options{Stream: Stream{SampleRate: 48000, Channels: 2}, Bits: 24}, maxDiff: 0.5 / 8388607},
+		{name: "32bit float stereo", opts: Options{Stream: Stream{SampleRate: 44100, Channels: 2}, Bits: 32}, maxDiff: 1e-7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels := tt.opts.NumChannels()
+			data := genSine()
+			if channels == 2 {
+				data = Interleave([][]float64{genSine(), Resample(genSine(), 44100, 88200)[:len(data)]})
+			}
+			var buf bytes.Buffer
+			if err := Encode(&buf, data, tt.opts); err != nil {
+				t.Fatalf("Encode() failed: %s", err)
+			}
+			got, format, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode() failed: %s", err)
+			}
+			wantFormat := Format{Channels: channels, SampleRate: tt.opts.SampleRate, Bits: tt.opts.Bits, Float: tt.opts.Bits == 32}
+			if format != wantFormat {
+				t.Errorf("format = %+v, want %+v", format, wantFormat)
+			}
+			parts := Deinterleave(data, channels)
+			for c := range parts {
+				parts[c] = Resample(parts[c], SourceFreq, tt.opts.SampleRate)
+			}
+			want := Interleave(parts)
+			if len(got) != len(want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(want))
+			}
+			for i := range want {
+				w := want[i]
+				if !format.Float {
+					// Resampling can overshoot, which gets clipped
+					w = math.Max(-1, math.Min(1, w))
+				}
+				if d := math.Abs(got[i] - w); d > tt.maxDiff {
+					t.Fatalf("sample %d = %f, want %f", i, got[i], w)
+				}
 			}
 		})
 	}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Dither selects the noise that is added to samples before they are
+// quantized.
+type Dither int
+
+const (
+	// DitherNone just rounds to the nearest value.
+	DitherNone Dither = iota
+	// DitherTPDF adds triangular noise with an amplitude of 1 LSB.
+	DitherTPDF
+	// DitherShaped adds TPDF noise and shapes the quantization error so
+	// that most of it ends up in the high frequencies. This helps most at
+	// low bit depths.
+	DitherShaped
+)
+
+// Quantize converts samples in [-1, 1] to signed integers with the given
+// number of bits. -1, 0 and 1 map to -(2^(bits-1)-1), 0 and 2^(bits-1)-1.
+// Samples outside of [-1, 1] are clipped. The dither noise is always
+// seeded the same way, so the result is reproducible.
+func Quantize(data []float64, bits int, dither Dither) []int32 {
+	return QuantizeChannel(data, bits, dither, 0)
+}
+
+// QuantizeChannel is Quantize for the given channel of a multi-channel
+// sound. Each channel gets its own dither noise, so that the noise isn't
+// correlated between the channels.
+func QuantizeChannel(data []float64, bits int, dither Dither, channel int) []int32 {
+	scale := float64(int64(1)<<uint(bits-1) - 1)
+	min, max := -scale-1, scale
+	rng := rand.New(rand.NewSource(1 + int64(channel)))
+
+	res := make([]int32, len(data))
+	lastErr := 0.0
+	for i, s := range data {
+		s = math.Max(-1, math.Min(1, s))
+		v := s * scale
+		if dither == DitherShaped {
+			// First-order error feedback: the noise becomes e[n] - e[n-1]
+			v -= lastErr
+		}
+		d := 0.0
+		if dither != DitherNone {
+			d = rng.Float64() - rng.Float64()
+		}
+		q := math.Max(min, math.Min(max, math.Round(v+d)))
+		lastErr = q - v
+		res[i] = int32(q)
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"math"
+)
+
+// Number of zero crossings of the sinc kernel on each side of a sample.
+const resampleZeroCrossings = 16
+
+// Fraction of the lower Nyquist frequency that is passed through.
+const resampleCutoff = 0.95
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// blackman evaluates a Blackman window that spans [-1, 1].
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+// Resample converts data sampled at fromFreq Hz to toFreq Hz using a
+// band-limited windowed-sinc interpolator. When downsampling, everything
+// above the new Nyquist frequency is filtered out.
+func Resample(data []float64, fromFreq, toFreq int) []float64 {
+	if fromFreq == toFreq {
+		return data
+	}
+
+	step := float64(fromFreq) / float64(toFreq)
+	fc := resampleCutoff
+	if toFreq < fromFreq {
+		fc *= float64(toFreq) / float64(fromFreq)
+	}
+	// Kernel half width, in input samples
+	width := resampleZeroCrossings / fc
+
+	res := make([]float64, int(int64(len(data))*int64(toFreq)/int64(fromFreq)))
+	for i := range res {
+		t := float64(i) * step
+		first := int(math.Ceil(t - width))
+		if first < 0 {
+			first = 0
+		}
+		last := int(math.Floor(t + width))
+		if last >= len(data) {
+			last = len(data) - 1
+		}
+		s := 0.0
+		for j := first; j <= last; j++ {
+			d := t - float64(j)
+			s += data[j] * fc * sinc(fc*d) * blackman(d/width)
+		}
+		res[i] = s
+	}
+	return res
+}
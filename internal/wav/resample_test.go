@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(freq float64, sampleRate, l int) []float64 {
+	buf := make([]float64, l)
+	for i := range buf {
+		buf[i] = math.Sin(2 * math.Pi * freq * float64(i) / float64(sampleRate))
+	}
+	return buf
+}
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		name     string
+		freq     float64
+		toFreq   int
+		wantGain float64
+	}{
+		{name: "Upsample to 48k", freq: 440, toFreq: 48000, wantGain: 1},
+		{name: "Downsample to 22.05k", freq: 440, toFreq: 22050, wantGain: 1},
+		{name: "Downsample to 8k", freq: 1000, toFreq: 8000, wantGain: 1},
+		{name: "Alias is removed at 8k", freq: 6000, toFreq: 8000, wantGain: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := sine(tt.freq, SourceFreq, SourceFreq/10)
+			got := Resample(data, SourceFreq, tt.toFreq)
+			if want := len(data) * tt.toFreq / SourceFreq; len(got) != want {
+				t.Fatalf("len() = %d, want %d", len(got), want)
+			}
+			want := sine(tt.freq, tt.toFreq, len(got))
+			// Ignore the edges, where the kernel runs out of data.
+			maxErr := 0.0
+			for i := 100; i < len(got)-100; i++ {
+				maxErr = math.Max(maxErr, math.Abs(got[i]-tt.wantGain*want[i]))
+			}
+			if maxErr > 0.01 {
+				t.Errorf("max error = %v", maxErr)
+			}
+		})
+	}
+}
+
+func TestResampleSameFreq(t *testing.T) {
+	data := sine(440, SourceFreq, 100)
+	got := Resample(data, SourceFreq, SourceFreq)
+	for i := range data {
+		if got[i] != data[i] {
+			t.Fatalf("got[%d] = %v, want %v", i, got[i], data[i])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import "fmt"
+
+// Stream describes the samples passed to the encoders, and the sample rate
+// they are encoded at.
+type Stream struct {
+	// SampleRate of the file in Hz. The samples are resampled if it
+	// differs from SourceRate.
+	SampleRate int
+	// SourceRate of the samples in Hz. 0 means SourceFreq.
+	SourceRate int
+	// Channels of the samples, which are interleaved. 0 means mono.
+	Channels int
+}
+
+// NumChannels returns the number of channels, which is 1 if Channels is 0.
+func (s Stream) NumChannels() int {
+	if s.Channels == 0 {
+		return 1
+	}
+	return s.Channels
+}
+
+func (s Stream) sourceRate() int {
+	if s.SourceRate == 0 {
+		return SourceFreq
+	}
+	return s.SourceRate
+}
+
+// Validate checks whether the stream is supported.
+func (s Stream) Validate() error {
+	if s.SampleRate <= 0 {
+		return fmt.Errorf("unsupported sample rate %d", s.SampleRate)
+	}
+	if s.SourceRate < 0 {
+		return fmt.Errorf("unsupported source sample rate %d", s.SourceRate)
+	}
+	if s.Channels < 0 || s.Channels > 2 {
+		return fmt.Errorf("unsupported number of channels %d", s.Channels)
+	}
+	return nil
+}
+
+// Prepare resamples each channel of data from SourceRate to SampleRate, and
+// returns the interleaved result.
+func (s Stream) Prepare(data []float64) []float64 {
+	parts := Deinterleave(data, s.NumChannels())
+	for c := range parts {
+		parts[c] = Resample(parts[c], s.sourceRate(), s.SampleRate)
+	}
+	return Interleave(parts)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2021 Andreas Signer <asigner@gmail.com>
+ *
+ * This file is part of gosfxr.
+ *
+ * gosfxr is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * gosfxr is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with gosfxr.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wav
+
+import (
+	"testing"
+)
+
+func TestStreamPrepare(t *testing.T) {
+	// One second of stereo, with a different signal on each channel
+	data := make([]float64, 2*SourceFreq)
+	for i := range data {
+		if i%2 == 0 {
+			data[i] = 0.5
+		}
+	}
+	got := Stream{SampleRate: 22050, Channels: 2}.Prepare(data)
+	if len(got) != 2*22050 {
+		t.Fatalf("got %d samples, want %d", len(got), 2*22050)
+	}
+	// Away from the edges, the channels keep their levels.
+	for i := 1000; i < len(got)-1000; i += 2 {
+		if got[i] < 0.49 || got[i] > 0.51 || got[i+1] < -0.01 || got[i+1] > 0.01 {
+			t.Fatalf("frame %d = (%f, %f), want (0.5, 0)", i/2, got[i], got[i+1])
+		}
+	}
+}
+
+func TestStreamValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  Stream
+		wantErr bool
+	}{
+		{"Defaults", Stream{SampleRate: 44100}, false},
+		{"Stereo", Stream{SampleRate: 48000, SourceRate: 22050, Channels: 2}, false},
+		{"Zero sample rate", Stream{}, true},
+		{"Negative source rate", Stream{SampleRate: 44100, SourceRate: -1}, true},
+		{"Too many channels", Stream{SampleRate: 44100, Channels: 3}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.stream.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}